@@ -3,8 +3,132 @@
 
 package main
 
-import "github.com/mattermost/matterbuild/server"
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/mattermost/matterbuild/server"
+)
+
+// Set with -ldflags at build time, see the Makefile.
+var (
+	Version   = "dev"
+	BuildHash = "unknown"
+)
 
 func main() {
-	server.Start()
+	rootCmd := &cobra.Command{
+		Use:           "matterbuild",
+		Short:         "Control of the build system through Mattermost slash commands.",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+	rootCmd.PersistentFlags().String("config", "config.json", "Path to the config file. If not given, config.json is searched in ./config, ../config and the working directory.")
+
+	serveCmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Run the matterbuild server",
+		RunE:  serveCmdF,
+	}
+	serveCmd.Flags().String("listen", "", "Address to listen on, overrides ListenAddress from the config.")
+
+	validateConfigCmd := &cobra.Command{
+		Use:   "validate-config",
+		Short: "Check that the config file, with environment overrides applied, is valid",
+		RunE:  validateConfigCmdF,
+	}
+
+	versionCmd := &cobra.Command{
+		Use:   "version",
+		Short: "Print the matterbuild version",
+		Run: func(cmd *cobra.Command, args []string) {
+			fmt.Printf("matterbuild %v (%v)\n", Version, BuildHash)
+		},
+	}
+
+	execCmd := &cobra.Command{
+		Use:   "exec <subcommand> [args]",
+		Short: "Run a matterbuild command locally, for when Mattermost is down",
+		Long:  "Run any of the matterbuild slash subcommands from a terminal. Waits for background operations, like a release started by cut, to finish before exiting.",
+		RunE:  execCmdF,
+	}
+	execCmd.Flags().SetInterspersed(false)
+	execCmd.Flags().String("user", os.Getenv("USER"), "Operator identity recorded in the logs.")
+	execCmd.Flags().String("channel", "", "Mattermost channel id to post release progress to.")
+	execCmd.Flags().String("format", "text", "Output format, text or json.")
+
+	rootCmd.AddCommand(serveCmd, validateConfigCmd, versionCmd, execCmd)
+
+	// Running the binary without a command keeps serving like it always did.
+	rootCmd.RunE = serveCmdF
+	rootCmd.Flags().AddFlagSet(serveCmd.Flags())
+
+	if err := rootCmd.Execute(); err != nil {
+		fmt.Fprintln(os.Stderr, "Error: "+err.Error())
+		os.Exit(1)
+	}
+}
+
+func configFilePath(cmd *cobra.Command) string {
+	configFile, _ := cmd.Flags().GetString("config")
+	if cmd.Flags().Changed("config") {
+		return configFile
+	}
+	return server.FindConfigFile(configFile)
+}
+
+func serveCmdF(cmd *cobra.Command, args []string) error {
+	listenAddress, _ := cmd.Flags().GetString("listen")
+	return server.Start(configFilePath(cmd), listenAddress)
+}
+
+func validateConfigCmdF(cmd *cobra.Command, args []string) error {
+	configFile := configFilePath(cmd)
+	if _, err := server.ReadConfig(configFile); err != nil {
+		return err
+	}
+
+	fmt.Println(configFile + " is valid")
+	return nil
+}
+
+func execCmdF(cmd *cobra.Command, args []string) error {
+	operator, _ := cmd.Flags().GetString("user")
+	channelId, _ := cmd.Flags().GetString("channel")
+	format, _ := cmd.Flags().GetString("format")
+
+	if len(args) < 1 {
+		return fmt.Errorf("you need to specify a subcommand")
+	}
+	if operator == "" {
+		return fmt.Errorf("an operator identity is required, use --user")
+	}
+	if format != "text" && format != "json" {
+		return fmt.Errorf("unknown format %v", format)
+	}
+
+	if err := server.LoadConfig(configFilePath(cmd)); err != nil {
+		return err
+	}
+
+	response, err := server.ExecuteLocalCommand(operator, channelId, args)
+	if err != nil {
+		return err
+	}
+
+	if format == "json" {
+		b, err := json.MarshalIndent(response, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+	} else {
+		fmt.Println(server.FormatResponseText(response))
+	}
+
+	server.WaitForOperations(0)
+	return nil
 }
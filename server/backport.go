@@ -0,0 +1,145 @@
+// Copyright (c) 2018-present Mattermost, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mattermost/matterbuild/version"
+)
+
+const (
+	BACKPORT_CHECK_ARTIFACT = "artifact"
+	BACKPORT_CHECK_TAGS     = "tags"
+	BACKPORT_CHECK_BRANCH   = "branch"
+
+	DEFAULT_RELEASE_ARTIFACT_URL_TEMPLATE = "https://releases.mattermost.com/{version}/mattermost-{version}-linux-amd64.tar.gz"
+	DEFAULT_BACKPORT_CHECK_TIMEOUT        = 10 * time.Second
+)
+
+// newerReleaseDetector looks for evidence that a release line newer than the version being cut exists,
+// in which case the release is probably a backport.
+type newerReleaseDetector interface {
+	// Detect returns a description of the evidence found, or an empty string if there is none.
+	Detect(ctx context.Context, v *version.Version) (string, *AppError)
+}
+
+var newerReleaseDetectors = map[string]newerReleaseDetector{
+	BACKPORT_CHECK_ARTIFACT: artifactDetector{},
+	BACKPORT_CHECK_TAGS:     tagsDetector{},
+	BACKPORT_CHECK_BRANCH:   branchDetector{},
+}
+
+// artifactDetector looks for the rc1 artifact of the next release line at ReleaseArtifactURLTemplate.
+type artifactDetector struct{}
+
+func (artifactDetector) Detect(ctx context.Context, v *version.Version) (string, *AppError) {
+	next := v.NextMinor()
+	next.Suffix = version.SUFFIX_RC
+	next.SuffixNumber = 1
+
+	template := Cfg().ReleaseArtifactURLTemplate
+	if template == "" {
+		template = DEFAULT_RELEASE_ARTIFACT_URL_TEMPLATE
+	}
+	artifactURL := strings.Replace(template, "{version}", next.String(), -1)
+
+	req, err := http.NewRequest(http.MethodHead, artifactURL, nil)
+	if err != nil {
+		return "", NewError("Invalid ReleaseArtifactURLTemplate", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return "", NewError("Unable to check the artifact "+artifactURL, err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", nil
+	}
+	return "the " + next.String() + " artifact exists at " + artifactURL, nil
+}
+
+// tagsDetector looks for tags of a newer release line in the configured Repositories.
+type tagsDetector struct{}
+
+func (tagsDetector) Detect(ctx context.Context, v *version.Version) (string, *AppError) {
+	for _, repo := range Cfg().Repositories {
+		versions, err := listVersionTags(ctx, repo)
+		if err != nil {
+			return "", err
+		}
+
+		var newer []*version.Version
+		for _, tagged := range versions {
+			if tagged.Major > v.Major || (tagged.Major == v.Major && tagged.Minor > v.Minor) {
+				newer = append(newer, tagged)
+			}
+		}
+		if latest := version.Latest(newer); latest != nil {
+			return "the " + repo.Owner + "/" + repo.Name + " repository has the tag for " + latest.String(), nil
+		}
+	}
+
+	return "", nil
+}
+
+// branchDetector looks for the release branch of the next release line in the configured Repositories.
+type branchDetector struct{}
+
+func (branchDetector) Detect(ctx context.Context, v *version.Version) (string, *AppError) {
+	branch := v.NextMinor().ReleaseBranch()
+	for _, repo := range Cfg().Repositories {
+		_, resp, err := githubClient().Repositories.GetBranch(ctx, repo.Owner, repo.Name, branch)
+		if err == nil {
+			return "the " + repo.Owner + "/" + repo.Name + " repository has the " + branch + " branch", nil
+		}
+		if resp == nil || resp.StatusCode != http.StatusNotFound {
+			githubAPIErrorsTotal.Inc(repo.Owner+"/"+repo.Name, "get_branch")
+			return "", NewError("Unable to check the "+branch+" branch of "+repo.Owner+"/"+repo.Name, err)
+		}
+	}
+
+	return "", nil
+}
+
+// detectNewerRelease runs the BackportChecks of the config, or the artifact check by default, and returns the
+// evidence found of a newer release line. Checks that fail are logged and don't block the release.
+func detectNewerRelease(ctx context.Context, v *version.Version) []string {
+	checks := Cfg().BackportChecks
+	if len(checks) == 0 {
+		checks = []string{BACKPORT_CHECK_ARTIFACT}
+	}
+
+	timeout := DEFAULT_BACKPORT_CHECK_TIMEOUT
+	if Cfg().BackportCheckTimeoutSeconds > 0 {
+		timeout = time.Duration(Cfg().BackportCheckTimeoutSeconds) * time.Second
+	}
+
+	var evidence []string
+	for _, check := range checks {
+		detector, ok := newerReleaseDetectors[check]
+		if !ok {
+			continue
+		}
+
+		checkCtx, cancel := context.WithTimeout(ctx, timeout)
+		found, err := detector.Detect(checkCtx, v)
+		cancel()
+		if err != nil {
+			LogErrorContext(ctx, "[detectNewerRelease] The "+check+" check failed for "+v.String()+" err="+err.Error())
+			continue
+		}
+		if found != "" {
+			evidence = append(evidence, fmt.Sprintf("%v check: %v", check, found))
+		}
+	}
+
+	return evidence
+}
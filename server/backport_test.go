@@ -0,0 +1,93 @@
+// Copyright (c) 2018-present Mattermost, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mattermost/matterbuild/version"
+)
+
+func TestDetectNewerRelease(t *testing.T) {
+	blocked := make(chan struct{})
+	published := map[string]bool{"/5.2.0-rc1/mattermost.tar.gz": true}
+	var requested []string
+	var requestedLock sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestedLock.Lock()
+		requested = append(requested, r.Method+" "+r.URL.Path)
+		requestedLock.Unlock()
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/slow/"):
+			<-blocked
+		case published[r.URL.Path]:
+			w.WriteHeader(http.StatusOK)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	defer close(blocked)
+
+	for _, tc := range []struct {
+		name     string
+		template string
+		version  string
+		checks   []string
+		expected string
+	}{
+		{name: "newer release", template: server.URL + "/{version}/mattermost.tar.gz", version: "5.1.0", expected: "artifact check: the 5.2.0-rc1 artifact exists at " + server.URL + "/5.2.0-rc1/mattermost.tar.gz"},
+		{name: "rc of the line", template: server.URL + "/{version}/mattermost.tar.gz", version: "5.1.0-rc3", checks: []string{BACKPORT_CHECK_ARTIFACT}, expected: "artifact check: the 5.2.0-rc1 artifact"},
+		{name: "no newer release", template: server.URL + "/{version}/mattermost.tar.gz", version: "5.2.0"},
+		{name: "unknown check", template: server.URL + "/{version}/mattermost.tar.gz", version: "5.1.0", checks: []string{"unknown"}},
+		{name: "unreachable", template: "http://127.0.0.1:1/{version}/mattermost.tar.gz", version: "5.1.0"},
+		{name: "invalid template", template: "http://%zz/{version}", version: "5.1.0"},
+		{name: "timeout", template: server.URL + "/slow/{version}", version: "5.1.0"},
+	} {
+		restore := setTestConfig(t, &MatterbuildConfig{
+			BackportChecks:              tc.checks,
+			ReleaseArtifactURLTemplate:  tc.template,
+			BackportCheckTimeoutSeconds: 1,
+		})
+
+		v, err := version.ParseRelease(tc.version)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		start := time.Now()
+		evidence := detectNewerRelease(context.Background(), v)
+		if tc.expected == "" && len(evidence) != 0 {
+			t.Errorf("%v: expected no evidence, got %v", tc.name, evidence)
+		} else if tc.expected != "" && (len(evidence) != 1 || !strings.HasPrefix(evidence[0], tc.expected)) {
+			t.Errorf("%v: got %v, expected %q", tc.name, evidence, tc.expected)
+		}
+		if time.Since(start) > 3*time.Second {
+			t.Errorf("%v: the check took %v, longer than its timeout", tc.name, time.Since(start))
+		}
+
+		restore()
+	}
+
+	requestedLock.Lock()
+	if len(requested) == 0 || requested[0] != "HEAD /5.2.0-rc1/mattermost.tar.gz" {
+		t.Errorf("expected a HEAD request for the artifact, got %v", requested)
+	}
+	requestedLock.Unlock()
+
+	// The checks stop with the command.
+	defer setTestConfig(t, &MatterbuildConfig{ReleaseArtifactURLTemplate: server.URL + "/slow/{version}", BackportCheckTimeoutSeconds: 60})()
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if evidence := detectNewerRelease(ctx, &version.Version{Major: 5, Minor: 1}); len(evidence) != 0 || time.Since(start) > 5*time.Second {
+		t.Errorf("expected the check to be canceled with the command, got %v after %v", evidence, time.Since(start))
+	}
+}
@@ -0,0 +1,361 @@
+// Copyright (c) 2018-present Mattermost, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mattermost/matterbuild/version"
+)
+
+const (
+	CALENDAR_FILE = "calendar.json"
+
+	MILESTONE_FEATURE_COMPLETE = "feature-complete"
+	MILESTONE_RC1              = "rc1"
+	MILESTONE_FINAL            = "final"
+
+	CALENDAR_DATE_FORMAT = "2006-01-02"
+
+	CALENDAR_BRANCH_CHECK_TIMEOUT = 10 * time.Second
+)
+
+var milestoneNames = map[string]string{
+	MILESTONE_FEATURE_COMPLETE: "Feature complete",
+	MILESTONE_RC1:              "RC1",
+	MILESTONE_FINAL:            "Final release",
+}
+
+var milestoneOrder = []string{MILESTONE_FEATURE_COMPLETE, MILESTONE_RC1, MILESTONE_FINAL}
+
+// CalendarReminder posts a reminder DaysBefore each milestone (all of them if Milestones is empty)
+// to the ChannelId, or the ReleaseChannelId.
+type CalendarReminder struct {
+	DaysBefore int
+	Milestones []string
+	ChannelId  string
+}
+
+// CalendarRelease is an upcoming release with the dates of its milestones, e.g. "rc1": "2018-06-04".
+type CalendarRelease struct {
+	Version       string
+	Milestones    map[string]string
+	ChannelId     string
+	UserId        string
+	Username      string
+	CreatedAt     time.Time
+	SentReminders []string
+}
+
+// loadCalendar returns the releases of the calendar.
+func loadCalendar() ([]*CalendarRelease, *AppError) {
+	var releases []*CalendarRelease
+	if _, err := loadJSON(CALENDAR_FILE, &releases); err != nil {
+		return nil, err
+	}
+	return releases, nil
+}
+
+// updateCalendar loads the calendar, lets update change it and saves it if it reports a change.
+func updateCalendar(update func(releases []*CalendarRelease) ([]*CalendarRelease, bool, *AppError)) *AppError {
+	var releases []*CalendarRelease
+	return updateJSON(CALENDAR_FILE, &releases, func() (bool, *AppError) {
+		updated, changed, err := update(releases)
+		releases = updated
+		return changed, err
+	})
+}
+
+func parseMilestoneDate(date string) (time.Time, error) {
+	return time.ParseInLocation(CALENDAR_DATE_FORMAT, date, time.Local)
+}
+
+func calendarReminders() []*CalendarReminder {
+	if len(Cfg().CalendarReminders) > 0 {
+		return Cfg().CalendarReminders
+	}
+	return []*CalendarReminder{{DaysBefore: 7}, {DaysBefore: 1}, {DaysBefore: 0}}
+}
+
+func (r *CalendarReminder) covers(milestone string) bool {
+	return containsOrEmpty(r.Milestones, milestone)
+}
+
+func reminderKey(milestone string, reminder *CalendarReminder) string {
+	return fmt.Sprintf("%v:%v", milestone, reminder.DaysBefore)
+}
+
+type dueReminder struct {
+	release   *CalendarRelease
+	milestone string
+	date      time.Time
+	reminder  *CalendarReminder
+}
+
+// sendCalendarReminders posts the reminders that are due. Reminders are sent from CalendarReminderHour on
+// the day they are due, and only once.
+func sendCalendarReminders(now time.Time) {
+	if now.Hour() < Cfg().CalendarReminderHour {
+		return
+	}
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	var due []*dueReminder
+	err := updateCalendar(func(releases []*CalendarRelease) ([]*CalendarRelease, bool, *AppError) {
+		for _, release := range releases {
+			for milestone, date := range release.Milestones {
+				milestoneDate, err := parseMilestoneDate(date)
+				if err != nil || milestoneDate.Before(today) {
+					continue
+				}
+
+				// When several reminders of a channel are due at once, e.g. for a release added the day
+				// before a milestone, only the closest one is sent.
+				dueByChannel := map[string]*dueReminder{}
+				for _, reminder := range calendarReminders() {
+					key := reminderKey(milestone, reminder)
+					if !reminder.covers(milestone) || (len(release.SentReminders) > 0 && containsOrEmpty(release.SentReminders, key)) {
+						continue
+					}
+					if !today.Before(milestoneDate.AddDate(0, 0, -reminder.DaysBefore)) {
+						release.SentReminders = append(release.SentReminders, key)
+						if closest := dueByChannel[reminder.ChannelId]; closest == nil || reminder.DaysBefore < closest.reminder.DaysBefore {
+							dueByChannel[reminder.ChannelId] = &dueReminder{release, milestone, milestoneDate, reminder}
+						}
+					}
+				}
+				for _, reminder := range dueByChannel {
+					due = append(due, reminder)
+				}
+			}
+		}
+		return releases, len(due) > 0, nil
+	})
+	if err != nil {
+		LogError("[sendCalendarReminders] Unable to load the calendar err=" + err.Error())
+		return
+	}
+
+	for _, reminder := range due {
+		sendCalendarReminder(reminder, today)
+	}
+}
+
+func sendCalendarReminder(due *dueReminder, today time.Time) {
+	channelId := due.reminder.ChannelId
+	if channelId == "" {
+		channelId = Cfg().ReleaseChannelId
+	}
+	if channelId == "" {
+		channelId = due.release.ChannelId
+	}
+
+	days := int(due.date.Sub(today).Hours()/24 + 0.5)
+	var when string
+	switch days {
+	case 0:
+		when = "is **today**"
+	case 1:
+		when = "is **tomorrow**"
+	default:
+		when = fmt.Sprintf("is in **%v days**", days)
+	}
+
+	ctx := WithLogFields(context.Background(), LogFields{"request_id": NewRequestId()})
+	msg := fmt.Sprintf("%v of **%v** %v (%v).\n", milestoneNames[due.milestone], due.release.Version, when, due.date.Format(CALENDAR_DATE_FORMAT))
+	if v, err := version.Parse(due.release.Version); err == nil {
+		msg += releaseBranchStatus(ctx, v.ReleaseBranch())
+	}
+
+	LogInfoContext(ctx, "[sendCalendarReminder] Reminder for "+due.milestone+" of "+due.release.Version)
+	postNotification(channelId, "Release Reminder", msg, "#0060aa")
+}
+
+// releaseBranchStatus reports if the release branch exists in every configured repository. GitHub gets
+// CALENDAR_BRANCH_CHECK_TIMEOUT to answer, the repositories it doesn't answer for are reported as unchecked.
+func releaseBranchStatus(ctx context.Context, branch string) string {
+	ctx, cancel := context.WithTimeout(ctx, CALENDAR_BRANCH_CHECK_TIMEOUT)
+	defer cancel()
+
+	var exists, missing, unknown []string
+	for _, repo := range Cfg().Repositories {
+		name := repo.Owner + "/" + repo.Name
+		_, resp, err := githubClient().Repositories.GetBranch(ctx, repo.Owner, repo.Name, branch)
+		if err == nil {
+			exists = append(exists, name)
+		} else if resp != nil && resp.StatusCode == http.StatusNotFound {
+			missing = append(missing, name)
+		} else {
+			githubAPIErrorsTotal.Inc(name, "get_branch")
+			unknown = append(unknown, name)
+		}
+	}
+
+	msg := ""
+	if len(missing) == 0 && len(unknown) == 0 {
+		return fmt.Sprintf("%v `%v` exists in all the repositories.", stepIcons[STEP_SUCCESS], branch)
+	}
+	if len(exists) > 0 {
+		msg += fmt.Sprintf("%v `%v` exists in %v\n", stepIcons[STEP_SUCCESS], branch, strings.Join(exists, ", "))
+	}
+	if len(missing) > 0 {
+		msg += fmt.Sprintf("%v `%v` doesn't exist yet in %v\n", stepIcons[STEP_FAILED], branch, strings.Join(missing, ", "))
+	}
+	if len(unknown) > 0 {
+		msg += fmt.Sprintf("Unable to check `%v` in %v\n", branch, strings.Join(unknown, ", "))
+	}
+	return msg
+}
+
+func formatMilestones(release *CalendarRelease) string {
+	var parts []string
+	for _, milestone := range milestoneOrder {
+		if date, ok := release.Milestones[milestone]; ok {
+			parts = append(parts, milestoneNames[milestone]+": "+date)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func calendarAddCmdF(args []string, w http.ResponseWriter, slashCommand *MMSlashCommand, milestones map[string]string, channelId string) error {
+	if len(args) < 1 {
+		return NewError("You need to specify the version of the release, e.g. `calendar add 5.1.0 --rc1 2018-06-04 --final 2018-06-16`.", nil)
+	}
+
+	v, err := version.Parse(args[0])
+	if err != nil || !v.IsFinal() {
+		WriteErrorResponse(w, NewError("Bad version argument, use the final version like 5.1.0.", err))
+		return nil
+	}
+
+	release := &CalendarRelease{
+		Version:    v.String(),
+		Milestones: map[string]string{},
+		ChannelId:  channelId,
+		UserId:     slashCommand.UserId,
+		Username:   slashCommand.Username,
+		CreatedAt:  time.Now(),
+	}
+	if release.ChannelId == "" {
+		release.ChannelId = slashCommand.ChannelId
+	}
+
+	for milestone, date := range milestones {
+		if date == "" {
+			continue
+		}
+		if _, err := parseMilestoneDate(date); err != nil {
+			WriteErrorResponse(w, NewError("Bad date for --"+milestone+", use a date like 2018-06-04.", err))
+			return nil
+		}
+		release.Milestones[milestone] = date
+	}
+	if len(release.Milestones) == 0 {
+		WriteErrorResponse(w, NewError("You need to specify at least one of --feature-complete, --rc1 or --final.", nil))
+		return nil
+	}
+
+	appErr := updateCalendar(func(releases []*CalendarRelease) ([]*CalendarRelease, bool, *AppError) {
+		for i, existing := range releases {
+			if existing.Version == release.Version {
+				// Keep the dates that aren't changed and the reminders of those already sent.
+				for milestone, date := range existing.Milestones {
+					if _, ok := release.Milestones[milestone]; !ok {
+						release.Milestones[milestone] = date
+					}
+				}
+				for _, key := range existing.SentReminders {
+					milestone := strings.SplitN(key, ":", 2)[0]
+					if existing.Milestones[milestone] == release.Milestones[milestone] {
+						release.SentReminders = append(release.SentReminders, key)
+					}
+				}
+				releases[i] = release
+				return releases, true, nil
+			}
+		}
+		return append(releases, release), true, nil
+	})
+	if appErr != nil {
+		return appErr
+	}
+
+	LogInfoContext(slashCommand.Context(), "[calendarAddCmdF] "+slashCommand.Username+" added "+release.Version+" to the calendar: "+formatMilestones(release))
+	msg := fmt.Sprintf("Added **%v** to the release calendar. %v\n%v", release.Version, formatMilestones(release), releaseBranchStatus(slashCommand.Context(), v.ReleaseBranch()))
+	WriteEnrichedResponse(w, "Release Calendar", msg, "#0060aa", IN_CHANNEL)
+	return nil
+}
+
+func calendarListCmdF(args []string, w http.ResponseWriter, slashCommand *MMSlashCommand) error {
+	releases, err := loadCalendar()
+	if err != nil {
+		return err
+	}
+
+	if len(releases) == 0 {
+		WriteEnrichedResponse(w, "Release Calendar", "There are no releases in the calendar.", "#0060aa", EPHEMERAL)
+		return nil
+	}
+
+	sort.Slice(releases, func(i, j int) bool {
+		a, errA := version.Parse(releases[i].Version)
+		b, errB := version.Parse(releases[j].Version)
+		if errA != nil || errB != nil {
+			return releases[i].Version < releases[j].Version
+		}
+		return a.LessThan(b)
+	})
+
+	msg := "| Version | Feature complete | RC1 | Final |\n|---|---|---|---|\n"
+	for _, release := range releases {
+		msg += fmt.Sprintf("| %v | %v | %v | %v |\n", release.Version, release.Milestones[MILESTONE_FEATURE_COMPLETE], release.Milestones[MILESTONE_RC1], release.Milestones[MILESTONE_FINAL])
+	}
+
+	WriteEnrichedResponse(w, "Release Calendar", msg, "#0060aa", EPHEMERAL)
+	return nil
+}
+
+func calendarRemoveCmdF(args []string, w http.ResponseWriter, slashCommand *MMSlashCommand) error {
+	if len(args) < 1 {
+		return NewError("You need to specify the version of the release.", nil)
+	}
+
+	// The calendar stores the versions as added, e.g. 5.1.0 for v5.1.0.
+	v, parseErr := version.Parse(args[0])
+	if parseErr != nil {
+		WriteErrorResponse(w, NewError("Bad version argument, use the final version like 5.1.0.", parseErr))
+		return nil
+	}
+	releaseVersion := v.String()
+
+	removed := false
+	err := updateCalendar(func(releases []*CalendarRelease) ([]*CalendarRelease, bool, *AppError) {
+		var kept []*CalendarRelease
+		for _, release := range releases {
+			if release.Version == releaseVersion {
+				removed = true
+			} else {
+				kept = append(kept, release)
+			}
+		}
+		return kept, removed, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if !removed {
+		WriteErrorResponse(w, NewError("There is no release "+releaseVersion+" in the calendar.", nil))
+		return nil
+	}
+
+	LogInfoContext(slashCommand.Context(), "[calendarRemoveCmdF] "+slashCommand.Username+" removed "+releaseVersion+" from the calendar")
+	WriteEnrichedResponse(w, "Release Calendar", "Removed **"+releaseVersion+"** from the release calendar.", "#0060aa", IN_CHANNEL)
+	return nil
+}
@@ -0,0 +1,73 @@
+// Copyright (c) 2018-present Mattermost, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package server
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSendCalendarReminders(t *testing.T) {
+	mattermost := newFakeMattermost()
+	defer mattermost.Close()
+	defer setTestConfig(t, mattermost.config(&MatterbuildConfig{
+		ReleaseChannelId:     "channel",
+		CalendarReminderHour: 9,
+	}))()
+
+	if err := saveJSON(CALENDAR_FILE, []*CalendarRelease{
+		{Version: "5.1.0", Milestones: map[string]string{MILESTONE_RC1: "2018-06-05", MILESTONE_FINAL: "2018-06-16"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	fileName := dataFilePath(CALENDAR_FILE)
+
+	// setOld backdates the calendar file so a save shows in its modification time.
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+	setOld := func() {
+		if err := os.Chtimes(fileName, old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+	saved := func() bool {
+		info, err := os.Stat(fileName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return !info.ModTime().Equal(old)
+	}
+
+	// Nothing is sent before the reminder hour.
+	setOld()
+	sendCalendarReminders(time.Date(2018, 6, 4, 8, 0, 0, 0, time.Local))
+	if saved() || len(mattermost.Posts()) != 0 {
+		t.Error("expected nothing to be sent or saved before the reminder hour")
+	}
+
+	// The day before the RC1 the reminder is sent and recorded.
+	sendCalendarReminders(time.Date(2018, 6, 4, 10, 0, 0, 0, time.Local))
+	if !saved() {
+		t.Error("expected the sent reminder to be saved")
+	}
+	posts := mattermost.waitForPosts(t, 1)
+	if text := attachmentText(posts[0]); posts[0].ChannelId != "channel" || !strings.Contains(text, "RC1 of **5.1.0** is **tomorrow** (2018-06-05)") {
+		t.Errorf("unexpected reminder %q in %v", text, posts[0].ChannelId)
+	}
+	releases, err := loadCalendar()
+	if err != nil || len(releases) != 1 || strings.Join(releases[0].SentReminders, ",") != "rc1:7,rc1:1" {
+		t.Fatalf("expected the RC1 reminders to be recorded, got %+v (err=%v)", releases, err)
+	}
+
+	// Later the same day nothing is due, the calendar isn't saved again.
+	setOld()
+	sendCalendarReminders(time.Date(2018, 6, 4, 18, 0, 0, 0, time.Local))
+	if saved() {
+		t.Error("expected the calendar not to be saved when nothing is sent")
+	}
+	if posts := mattermost.Posts(); len(posts) != 1 {
+		t.Errorf("expected the reminder to be sent once, got %v posts", len(posts))
+	}
+}
@@ -0,0 +1,306 @@
+// Copyright (c) 2018-present Mattermost, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/google/go-github/github"
+)
+
+// The pull request of a commit is found in the message of merge commits, "Merge pull request #123 from ...",
+// and of squashed commits, "Fix the thing (#123)".
+var (
+	mergeCommitPattern    = regexp.MustCompile(`^Merge pull request #(\d+) `)
+	squashedCommitPattern = regexp.MustCompile(`\(#(\d+)\)\s*$`)
+)
+
+// The refs compared are tags or branch names, "v5.1.0" or "release-5.1", they end up in the request path.
+var changelogRefPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._/-]*$`)
+
+const (
+	CHANGELOG_OTHER_LABEL = "Other"
+
+	CHANGELOG_COMPARE_PAGE_SIZE = 100
+)
+
+// ChangelogEntry is a pull request merged between the two refs of a changelog.
+type ChangelogEntry struct {
+	Repository string
+	Number     int
+	Title      string
+	URL        string
+	Author     string
+	Labels     []string
+}
+
+func pullRequestNumber(commitMessage string) (int, bool) {
+	firstLine := strings.SplitN(commitMessage, "\n", 2)[0]
+	for _, pattern := range []*regexp.Regexp{mergeCommitPattern, squashedCommitPattern} {
+		if matches := pattern.FindStringSubmatch(firstLine); matches != nil {
+			number, err := strconv.Atoi(matches[1])
+			return number, err == nil
+		}
+	}
+	return 0, false
+}
+
+func validChangelogRef(ref string) bool {
+	return changelogRefPattern.MatchString(ref) && !strings.Contains(ref, "..") && !strings.HasSuffix(ref, "/")
+}
+
+// compareCommits compares the from and to refs and returns all the commits between them. Without paging
+// the GitHub API returns at most 250 commits, so the comparison is requested page by page.
+func compareCommits(ctx context.Context, client *github.Client, owner, repo, from, to string) (*github.CommitsComparison, error) {
+	for _, ref := range []string{from, to} {
+		if !validChangelogRef(ref) {
+			return nil, fmt.Errorf("invalid ref %q", ref)
+		}
+	}
+
+	var comparison *github.CommitsComparison
+	for page := 1; page > 0; {
+		u := fmt.Sprintf("repos/%v/%v/compare/%v...%v?per_page=%v&page=%v", owner, repo, url.PathEscape(from), url.PathEscape(to), CHANGELOG_COMPARE_PAGE_SIZE, page)
+		req, err := client.NewRequest("GET", u, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		pageComparison := new(github.CommitsComparison)
+		resp, err := client.Do(ctx, req, pageComparison)
+		if err != nil {
+			return nil, err
+		}
+
+		if comparison == nil {
+			comparison = pageComparison
+		} else {
+			comparison.Commits = append(comparison.Commits, pageComparison.Commits...)
+		}
+		if len(pageComparison.Commits) == 0 || len(comparison.Commits) >= comparison.GetTotalCommits() {
+			break
+		}
+		page = resp.NextPage
+	}
+
+	return comparison, nil
+}
+
+// listMergedPullRequests returns the pull requests merged in the repository between the from and to tags or
+// branches. The warning tells when GitHub didn't return all the commits.
+func listMergedPullRequests(ctx context.Context, repository *Repository, from, to string) ([]*ChangelogEntry, string, *AppError) {
+	name := repository.Owner + "/" + repository.Name
+	client := githubClient()
+
+	comparison, err := compareCommits(ctx, client, repository.Owner, repository.Name, from, to)
+	if err != nil {
+		githubAPIErrorsTotal.Inc(name, "compare_commits")
+		return nil, "", NewError("Unable to compare "+from+" and "+to+" in "+name, err)
+	}
+
+	warning := ""
+	if comparison.GetTotalCommits() > len(comparison.Commits) {
+		warning = fmt.Sprintf("Only %v of the %v commits of %v are included.", len(comparison.Commits), comparison.GetTotalCommits(), name)
+	}
+
+	var entries []*ChangelogEntry
+	seen := map[int]bool{}
+	for _, commit := range comparison.Commits {
+		number, ok := pullRequestNumber(commit.GetCommit().GetMessage())
+		if !ok || seen[number] {
+			continue
+		}
+		seen[number] = true
+
+		issue, _, err := client.Issues.Get(ctx, repository.Owner, repository.Name, number)
+		if err != nil {
+			githubAPIErrorsTotal.Inc(name, "get_issue")
+			LogError("[listMergedPullRequests] Unable to get " + name + "#" + strconv.Itoa(number) + " err=" + err.Error())
+			entries = append(entries, &ChangelogEntry{
+				Repository: name,
+				Number:     number,
+				Title:      strings.SplitN(commit.GetCommit().GetMessage(), "\n", 2)[0],
+				URL:        fmt.Sprintf("https://github.com/%v/pull/%v", name, number),
+			})
+			continue
+		}
+
+		entry := &ChangelogEntry{
+			Repository: name,
+			Number:     number,
+			Title:      issue.GetTitle(),
+			URL:        issue.GetHTMLURL(),
+			Author:     issue.GetUser().GetLogin(),
+		}
+		for _, label := range issue.Labels {
+			entry.Labels = append(entry.Labels, label.GetName())
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, warning, nil
+}
+
+// changelogGroup returns the label a pull request is listed under: the first of the ChangelogLabels it has,
+// or when they aren't configured the first of its labels in alphabetical order.
+func changelogGroup(entry *ChangelogEntry) string {
+	if len(entry.Labels) == 0 {
+		return CHANGELOG_OTHER_LABEL
+	}
+
+	if len(Cfg().ChangelogLabels) > 0 {
+		for _, label := range Cfg().ChangelogLabels {
+			if containsOrEmpty(entry.Labels, label) {
+				return label
+			}
+		}
+		return CHANGELOG_OTHER_LABEL
+	}
+
+	labels := append([]string{}, entry.Labels...)
+	sort.Strings(labels)
+	return labels[0]
+}
+
+// formatChangelog renders the pull requests grouped by label, in the order of the ChangelogLabels.
+func formatChangelog(from, to string, entries []*ChangelogEntry, warnings []string) string {
+	groups := map[string][]*ChangelogEntry{}
+	for _, entry := range entries {
+		group := changelogGroup(entry)
+		groups[group] = append(groups[group], entry)
+	}
+
+	var order []string
+	for _, label := range Cfg().ChangelogLabels {
+		if _, ok := groups[label]; ok {
+			order = append(order, label)
+		}
+	}
+	if len(order) == 0 {
+		for label := range groups {
+			if label != CHANGELOG_OTHER_LABEL {
+				order = append(order, label)
+			}
+		}
+		sort.Strings(order)
+	}
+	if _, ok := groups[CHANGELOG_OTHER_LABEL]; ok {
+		order = append(order, CHANGELOG_OTHER_LABEL)
+	}
+
+	msg := fmt.Sprintf("### Changelog from %v to %v\n", from, to)
+	if len(entries) == 0 {
+		msg += "No pull requests were merged.\n"
+	}
+	for _, label := range order {
+		msg += "\n#### " + label + "\n"
+		for _, entry := range groups[label] {
+			msg += fmt.Sprintf("- %v ([%v#%v](%v))", entry.Title, entry.Repository, entry.Number, entry.URL)
+			if entry.Author != "" {
+				msg += " by @" + entry.Author
+			}
+			msg += "\n"
+		}
+	}
+
+	if len(warnings) > 0 {
+		msg += "\n#### Warnings\n- " + strings.Join(warnings, "\n- ") + "\n"
+	}
+
+	return msg
+}
+
+// generateChangelog lists the pull requests merged between the from and to refs in all the repositories.
+func generateChangelog(ctx context.Context, from, to string) (string, int, *AppError) {
+	var entries []*ChangelogEntry
+	var warnings []string
+	failures := 0
+	for _, repo := range Cfg().Repositories {
+		repoEntries, warning, err := listMergedPullRequests(ctx, repo, from, to)
+		if err != nil {
+			LogErrorContext(ctx, "[generateChangelog] "+err.Error())
+			warnings = append(warnings, err.Error())
+			failures++
+			continue
+		}
+		if warning != "" {
+			warnings = append(warnings, warning)
+		}
+		entries = append(entries, repoEntries...)
+	}
+
+	if failures > 0 && failures == len(Cfg().Repositories) {
+		return "", 0, NewError("Unable to compare "+from+" and "+to+" in any of the repositories:\n- "+strings.Join(warnings, "\n- "), nil)
+	}
+
+	return formatChangelog(from, to, entries, warnings), len(entries), nil
+}
+
+// postChangelog generates the changelog and posts it to the channel, as a message or as an attached file.
+func postChangelog(ctx context.Context, client *MattermostClient, channelId, username, from, to string, attach bool) {
+	changelog, count, err := generateChangelog(ctx, from, to)
+	if err != nil {
+		postNotification(channelId, "Changelog", err.Error(), "#e20025")
+		return
+	}
+	LogInfoContext(ctx, fmt.Sprintf("[postChangelog] %v generated the changelog from %v to %v with %v pull requests", username, from, to, count))
+
+	if !attach {
+		postNotification(channelId, "Changelog", changelog, "#0060aa")
+		return
+	}
+
+	fileName := strings.Replace(fmt.Sprintf("changelog-%v-%v.md", from, to), "/", "-", -1)
+	fileId, err := client.UploadFile(channelId, fileName, []byte(changelog))
+	if err != nil {
+		LogErrorContext(ctx, "[postChangelog] Unable to upload "+fileName+" err="+err.Error())
+		postNotification(channelId, "Changelog", "Unable to attach the changelog: "+err.Error(), "#e20025")
+		return
+	}
+
+	msg := fmt.Sprintf("Changelog from **%v** to **%v**: %v pull requests.", from, to, count)
+	if _, err := client.CreatePost(&MMPost{ChannelId: channelId, Message: msg, FileIds: []string{fileId}}); err != nil {
+		LogErrorContext(ctx, "[postChangelog] Unable to post "+fileName+" err="+err.Error())
+	}
+}
+
+func changelogCmdF(args []string, w http.ResponseWriter, slashCommand *MMSlashCommand, attach bool) error {
+	if len(args) < 2 {
+		return NewError("You need to specify the two tags or branches to compare, e.g. `changelog v5.0.0 v5.1.0`.", nil)
+	}
+	from, to := args[0], args[1]
+
+	// Getting every pull request takes longer than a slash command can wait, so the changelog is posted
+	// when it is ready. Without the Mattermost API, e.g. in the local exec mode, it is the response.
+	client := NewMattermostClient()
+	if client == nil || slashCommand.ChannelId == "" {
+		if attach {
+			return NewError("Attaching the changelog needs MattermostURL, MattermostBotToken and a channel.", nil)
+		}
+
+		changelog, count, err := generateChangelog(slashCommand.Context(), from, to)
+		if err != nil {
+			return err
+		}
+		LogInfoContext(slashCommand.Context(), fmt.Sprintf("[changelogCmdF] %v generated the changelog from %v to %v with %v pull requests", slashCommand.Username, from, to, count))
+		WriteEnrichedResponse(w, "Changelog", changelog, "#0060aa", IN_CHANNEL)
+		return nil
+	}
+
+	op := StartOperation("Changelog "+from+"..."+to, slashCommand.UserId, LogFieldsFrom(slashCommand.Context())["request_id"], map[string]string{"from": from, "to": to})
+	go func() {
+		defer op.Finish()
+		postChangelog(slashCommand.Context(), client, slashCommand.ChannelId, slashCommand.Username, from, to, attach)
+	}()
+
+	WriteEnrichedResponse(w, "Changelog", "Generating the changelog from **"+from+"** to **"+to+"**, it will be posted in this channel.", "#0060aa", EPHEMERAL)
+	return nil
+}
@@ -0,0 +1,121 @@
+// Copyright (c) 2018-present Mattermost, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/google/go-github/github"
+)
+
+func TestPullRequestNumber(t *testing.T) {
+	for _, tc := range []struct {
+		message  string
+		expected int
+		found    bool
+	}{
+		{message: "Merge pull request #123 from mattermost/fix-thing\n\nFix the thing", expected: 123, found: true},
+		{message: "Fix the thing (#456)", expected: 456, found: true},
+		{message: "Fix the thing (#456)  \n\n* Review fixes", expected: 456, found: true},
+		{message: "MM-1234 Fix the thing (#7) (#8)", expected: 8, found: true},
+		{message: "Fix the thing\n\nCherry-picked from (#456)"},
+		{message: "Fix #456 in the thing"},
+		{message: "Merge branch 'release-5.1'"},
+		{message: "Merge pull request #abc from mattermost/fix-thing"},
+		{message: ""},
+	} {
+		number, found := pullRequestNumber(tc.message)
+		if found != tc.found || number != tc.expected {
+			t.Errorf("%q: got %v (found %v), expected %v (found %v)", tc.message, number, found, tc.expected, tc.found)
+		}
+	}
+}
+
+func TestCompareCommitsPages(t *testing.T) {
+	const total = 230
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/mattermost/mattermost-server/compare/v5.0.0...v5.1.0" {
+			http.NotFound(w, r)
+			return
+		}
+
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+		if page < 1 || perPage < 1 {
+			t.Errorf("the comparison was not paged: %v", r.URL.RawQuery)
+			return
+		}
+
+		first := (page - 1) * perPage
+		last := first + perPage
+		if last > total {
+			last = total
+		}
+		if last < total {
+			w.Header().Set("Link", fmt.Sprintf(`<%v%v?per_page=%v&page=%v>; rel="next"`, server.URL, r.URL.Path, perPage, page+1))
+		}
+
+		fmt.Fprintf(w, `{"total_commits": %v, "commits": [`, total)
+		for i := first; i < last; i++ {
+			if i > first {
+				fmt.Fprint(w, ",")
+			}
+			fmt.Fprintf(w, `{"sha": "%v", "commit": {"message": "Change %v (#%v)"}}`, i, i, i)
+		}
+		fmt.Fprint(w, "]}")
+	}))
+	defer server.Close()
+
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	comparison, err := compareCommits(context.Background(), client, "mattermost", "mattermost-server", "v5.0.0", "v5.1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(comparison.Commits) != total {
+		t.Fatalf("expected the %v commits, got %v", total, len(comparison.Commits))
+	}
+	for i, commit := range comparison.Commits {
+		if commit.GetSHA() != strconv.Itoa(i) {
+			t.Fatalf("commit %v is %v", i, commit.GetSHA())
+		}
+	}
+}
+
+func TestCompareCommitsInvalidRefs(t *testing.T) {
+	requested := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = true
+		fmt.Fprint(w, `{"total_commits": 0, "commits": []}`)
+	}))
+	defer server.Close()
+
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	for _, ref := range []string{"", "../../user", "v5.0.0...master", "v5.1.0?per_page=1", "v5.1.0#x", "v5.1.0%2F..", "/v5.1.0", "-v5.1.0", "release-5.1/", "v5.1.0 x", "v5.1.0\n"} {
+		if _, err := compareCommits(context.Background(), client, "mattermost", "mattermost-server", ref, "v5.1.0"); err == nil {
+			t.Errorf("%q: expected an error", ref)
+		}
+		if _, err := compareCommits(context.Background(), client, "mattermost", "mattermost-server", "v5.0.0", ref); err == nil {
+			t.Errorf("%q: expected an error", ref)
+		}
+	}
+	if requested {
+		t.Error("expected no request with an invalid ref")
+	}
+
+	if _, err := compareCommits(context.Background(), client, "mattermost", "mattermost-server", "v5.0.0", "release-5.1"); err != nil || !requested {
+		t.Errorf("expected a valid ref to be compared, got %v", err)
+	}
+}
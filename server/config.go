@@ -5,19 +5,31 @@ package server
 
 import (
 	"encoding/json"
+	"fmt"
+	"net/url"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
 )
 
+const CONFIG_WATCH_INTERVAL = 10 * time.Second
+
 type MatterbuildConfig struct {
-	ListenAddress   string
-	JenkinsURL      string
-	JenkinsUsername string
-	JenkinsPassword string
+	ListenAddress        string
+	MetricsListenAddress string
+	JenkinsURL           string
+	JenkinsUsername      string
+	JenkinsPassword      string
 
 	AllowedTokens []string
+	ScopedTokens  []*ScopedToken
 	AllowedUsers  []string
 	ReleaseUsers  []string
+	ReleaseAdmins []string
 
 	CIServerJobs []string
 
@@ -34,6 +46,63 @@ type MatterbuildConfig struct {
 	PreReleaseJob string
 
 	KubeDeployJob string
+
+	MattermostURL      string
+	MattermostBotToken string
+	ReleaseChannelId   string
+
+	JenkinsWebhookSecret string
+
+	GithubWebhookSecret         string
+	GithubNotificationChannelId string
+
+	DataDirectory          string
+	ShutdownTimeoutSeconds int
+
+	TLSCertFile        string
+	TLSKeyFile         string
+	TrustedProxies     []string
+	AllowedSourceCIDRs []string
+
+	LogLevel      string
+	LogFormat     string
+	LogOutput     string
+	LogMaxSizeMB  int
+	LogMaxBackups int
+
+	RedactPatterns []string
+
+	UserRateLimit                  *RateLimit
+	CommandRateLimits              map[string]*RateLimit
+	GlobalCommandRateLimits        map[string]*RateLimit
+	MaxConcurrentOperationsPerUser int
+
+	BackportChecks              []string
+	ReleaseArtifactURLTemplate  string
+	BackportCheckTimeoutSeconds int
+
+	PreflightChecks []*PreflightCheck
+
+	FreezeWindows []*FreezeWindow
+
+	CalendarReminders    []*CalendarReminder
+	CalendarReminderHour int
+
+	ChangelogLabels []string
+}
+
+// RateLimit allows RequestsPerMinute commands on average, with bursts of up to Burst commands.
+type RateLimit struct {
+	RequestsPerMinute int
+	Burst             int
+}
+
+// ScopedToken is a slash command or outgoing webhook token only allowed to run some commands,
+// so separate triggers can be installed with different powers. Empty lists allow everything.
+type ScopedToken struct {
+	Token    string
+	Commands []string
+	Teams    []string
 }
 
 type Repository struct {
@@ -41,7 +110,19 @@ type Repository struct {
 	Name  string
 }
 
-var Cfg *MatterbuildConfig = &MatterbuildConfig{}
+var currentConfig atomic.Value
+
+// listenAddressOverride is set from the command line and takes precedence over the config file and environment.
+var listenAddressOverride string
+
+func init() {
+	currentConfig.Store(&MatterbuildConfig{})
+}
+
+// Cfg returns the active configuration. The returned value must not be modified, a reload swaps it for a new one.
+func Cfg() *MatterbuildConfig {
+	return currentConfig.Load().(*MatterbuildConfig)
+}
 
 func FindConfigFile(fileName string) string {
 	if _, err := os.Stat("./config/" + fileName); err == nil {
@@ -55,18 +136,271 @@ func FindConfigFile(fileName string) string {
 	return fileName
 }
 
-func LoadConfig(fileName string) {
-	fileName = FindConfigFile(fileName)
-	LogInfo("Loading " + fileName)
-
+// ReadConfig decodes the config file, applies the environment overrides and validates the result without activating it.
+func ReadConfig(fileName string) (*MatterbuildConfig, *AppError) {
 	file, err := os.Open(fileName)
 	if err != nil {
-		LogError("Error opening config file=" + fileName + ", err=" + err.Error())
+		return nil, NewError("Error opening config file="+fileName, err)
 	}
+	defer file.Close()
 
+	config := &MatterbuildConfig{}
 	decoder := json.NewDecoder(file)
-	err = decoder.Decode(Cfg)
+	if err := decoder.Decode(config); err != nil {
+		return nil, NewError("Error decoding config file="+fileName, err)
+	}
+
+	if err := applyEnvOverrides(config); err != nil {
+		return nil, err
+	}
+
+	if listenAddressOverride != "" {
+		config.ListenAddress = listenAddressOverride
+	}
+
+	if err := config.IsValid(); err != nil {
+		return nil, NewError("Invalid config file="+fileName, err)
+	}
+
+	return config, nil
+}
+
+// LoadConfig reads the config file and, if it is valid, atomically replaces the active configuration.
+func LoadConfig(fileName string) *AppError {
+	LogInfo("Loading " + fileName)
+
+	config, err := ReadConfig(fileName)
 	if err != nil {
-		LogError("Error decoding config file=" + fileName + ", err=" + err.Error())
+		LogError(err.Error())
+		return err
+	}
+
+	currentConfig.Store(config)
+
+	if err := ConfigureLogging(config); err != nil {
+		LogError("Unable to configure logging. err=" + err.Error())
+	}
+	return nil
+}
+
+// IsValid checks that the settings needed to run matterbuild are present and well formed.
+func (c *MatterbuildConfig) IsValid() *AppError {
+	var problems []string
+
+	if c.ListenAddress == "" {
+		problems = append(problems, "ListenAddress is required")
+	}
+
+	if c.JenkinsURL == "" {
+		problems = append(problems, "JenkinsURL is required")
+	} else if _, err := url.ParseRequestURI(c.JenkinsURL); err != nil {
+		problems = append(problems, "JenkinsURL is not a valid URL")
+	}
+
+	requiredJobs := []struct{ setting, job string }{
+		{"ReleaseJob", c.ReleaseJob},
+		{"PreChecksJob", c.PreChecksJob},
+		{"RCTestingJob", c.RCTestingJob},
+		{"OSSServerJob", c.OSSServerJob},
+		{"PreReleaseJob", c.PreReleaseJob},
+	}
+	for _, required := range requiredJobs {
+		if required.job == "" {
+			problems = append(problems, required.setting+" is required")
+		}
+	}
+
+	for _, job := range c.CIServerJobs {
+		if job == "" {
+			problems = append(problems, "CIServerJobs can't contain empty job names")
+			break
+		}
+	}
+
+	if len(c.AllowedTokens) == 0 && len(c.ScopedTokens) == 0 {
+		problems = append(problems, "at least one of AllowedTokens or ScopedTokens is required")
+	}
+	for _, token := range c.AllowedTokens {
+		if token == "" {
+			problems = append(problems, "AllowedTokens can't contain empty tokens")
+			break
+		}
+	}
+	for _, scopedToken := range c.ScopedTokens {
+		if scopedToken == nil || scopedToken.Token == "" {
+			problems = append(problems, "ScopedTokens can't contain empty tokens")
+			break
+		}
+	}
+
+	if len(c.AllowedUsers) == 0 {
+		problems = append(problems, "AllowedUsers is required")
+	}
+
+	if len(c.Repositories) == 0 {
+		problems = append(problems, "Repositories is required")
+	}
+	for i, repo := range c.Repositories {
+		if repo == nil || repo.Owner == "" || repo.Name == "" {
+			problems = append(problems, fmt.Sprintf("Repositories[%v] needs an Owner and a Name", i))
+		}
+	}
+
+	if c.MattermostURL != "" {
+		if _, err := url.ParseRequestURI(c.MattermostURL); err != nil {
+			problems = append(problems, "MattermostURL is not a valid URL")
+		}
+		if c.MattermostBotToken == "" {
+			problems = append(problems, "MattermostBotToken is required when MattermostURL is set")
+		}
+	}
+
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		problems = append(problems, "TLSCertFile and TLSKeyFile must be set together")
+	}
+
+	if _, err := parseCIDRs(c.TrustedProxies); err != nil {
+		problems = append(problems, "TrustedProxies contains an invalid CIDR: "+err.Error())
+	}
+
+	if _, err := parseCIDRs(c.AllowedSourceCIDRs); err != nil {
+		problems = append(problems, "AllowedSourceCIDRs contains an invalid CIDR: "+err.Error())
+	}
+
+	if _, ok := logLevels[c.LogLevel]; c.LogLevel != "" && !ok {
+		problems = append(problems, "LogLevel must be one of debug, info, error or crit")
+	}
+
+	if c.LogFormat != "" && c.LogFormat != LOG_FORMAT_LOGFMT && c.LogFormat != LOG_FORMAT_JSON {
+		problems = append(problems, "LogFormat must be logfmt or json")
+	}
+
+	if c.LogMaxSizeMB < 0 || c.LogMaxBackups < 0 {
+		problems = append(problems, "LogMaxSizeMB and LogMaxBackups can't be negative")
+	}
+
+	if _, err := compileRedactPatterns(c.RedactPatterns); err != nil {
+		problems = append(problems, "RedactPatterns contains an invalid regular expression: "+err.Error())
+	}
+
+	if c.UserRateLimit != nil && (c.UserRateLimit.RequestsPerMinute < 0 || c.UserRateLimit.Burst < 0) {
+		problems = append(problems, "UserRateLimit can't be negative")
 	}
+	for command, limit := range c.CommandRateLimits {
+		if limit == nil || limit.RequestsPerMinute < 0 || limit.Burst < 0 {
+			problems = append(problems, "CommandRateLimits for "+command+" can't be empty or negative")
+		}
+	}
+	for command, limit := range c.GlobalCommandRateLimits {
+		if limit == nil || limit.RequestsPerMinute < 0 || limit.Burst < 0 {
+			problems = append(problems, "GlobalCommandRateLimits for "+command+" can't be empty or negative")
+		}
+	}
+
+	for _, check := range c.BackportChecks {
+		if _, ok := newerReleaseDetectors[check]; !ok {
+			problems = append(problems, "BackportChecks must contain only artifact, tags or branch")
+			break
+		}
+	}
+	if c.BackportCheckTimeoutSeconds < 0 {
+		problems = append(problems, "BackportCheckTimeoutSeconds can't be negative")
+	}
+
+	for i, check := range c.PreflightChecks {
+		if check == nil {
+			problems = append(problems, fmt.Sprintf("PreflightChecks[%v] can't be empty", i))
+		} else if _, ok := preflightRunners[check.Type]; !ok {
+			problems = append(problems, fmt.Sprintf("PreflightChecks[%v] Type must be one of jenkins, branch, blockers, ci or translation", i))
+		} else if check.Type == PREFLIGHT_JENKINS && check.Job == "" {
+			problems = append(problems, fmt.Sprintf("PreflightChecks[%v] needs a Job", i))
+		} else if check.Type == PREFLIGHT_TRANSLATION && c.CheckTranslationServerJob == "" {
+			problems = append(problems, fmt.Sprintf("PreflightChecks[%v] needs the CheckTranslationServerJob", i))
+		}
+	}
+
+	for i, window := range c.FreezeWindows {
+		if window == nil {
+			problems = append(problems, fmt.Sprintf("FreezeWindows[%v] can't be empty", i))
+		} else if err := window.IsValid(); err != nil {
+			problems = append(problems, fmt.Sprintf("FreezeWindows[%v] is invalid: %v", i, err.Error()))
+		}
+	}
+
+	for i, reminder := range c.CalendarReminders {
+		if reminder == nil || reminder.DaysBefore < 0 {
+			problems = append(problems, fmt.Sprintf("CalendarReminders[%v] needs a DaysBefore that isn't negative", i))
+			continue
+		}
+		for _, milestone := range reminder.Milestones {
+			if _, ok := milestoneNames[milestone]; !ok {
+				problems = append(problems, fmt.Sprintf("CalendarReminders[%v] Milestones must be feature-complete, rc1 or final", i))
+				break
+			}
+		}
+	}
+	if c.CalendarReminderHour < 0 || c.CalendarReminderHour > 23 {
+		problems = append(problems, "CalendarReminderHour must be between 0 and 23")
+	}
+
+	if c.ShutdownTimeoutSeconds < 0 {
+		problems = append(problems, "ShutdownTimeoutSeconds can't be negative")
+	}
+
+	if len(problems) > 0 {
+		return NewError(strings.Join(problems, "; "), nil)
+	}
+
+	return nil
+}
+
+// WatchConfig reloads the config file on SIGHUP or when it changes on disk. An invalid file is
+// reported and ignored, the previous configuration stays active.
+func WatchConfig(fileName string) {
+	watchConfig(fileName, CONFIG_WATCH_INTERVAL, nil)
+}
+
+// watchConfig checks the modification time of the file every interval until stop is closed.
+func watchConfig(fileName string, interval time.Duration, stop <-chan struct{}) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	var lastModified time.Time
+	if info, err := os.Stat(fileName); err == nil {
+		lastModified = info.ModTime()
+	}
+
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		defer signal.Stop(hup)
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-hup:
+				LogInfo("Received SIGHUP, reloading config")
+			case <-ticker.C:
+				info, err := os.Stat(fileName)
+				if err != nil || !info.ModTime().After(lastModified) {
+					continue
+				}
+				lastModified = info.ModTime()
+				LogInfo("Config file changed, reloading config")
+			}
+
+			previous := Cfg()
+			if err := LoadConfig(fileName); err != nil {
+				LogError("Keeping the previous config. err=" + err.Error())
+				continue
+			}
+			if previous.ListenAddress != Cfg().ListenAddress {
+				LogError("ListenAddress changed, restart matterbuild for it to take effect")
+			}
+			if previous.MetricsListenAddress != Cfg().MetricsListenAddress {
+				LogError("MetricsListenAddress changed, restart matterbuild for it to take effect")
+			}
+		}
+	}()
 }
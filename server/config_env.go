@@ -0,0 +1,109 @@
+// Copyright (c) 2018-present Mattermost, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package server
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const ENV_PREFIX = "MB_"
+const ENV_FILE_SUFFIX = "_FILE"
+
+// EnvName returns the environment variable overriding a config setting, e.g. MB_JENKINS_PASSWORD for JenkinsPassword.
+func EnvName(setting string) string {
+	runes := []rune(setting)
+	name := ""
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) {
+			previous := runes[i-1]
+			nextIsLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			// A lowercase s ending an acronym makes it plural, e.g. AllowedSourceCIDRs.
+			plural := nextIsLower && runes[i+1] == 's' && (i+2 == len(runes) || unicode.IsUpper(runes[i+2]))
+			if unicode.IsLower(previous) || unicode.IsDigit(previous) || (unicode.IsUpper(previous) && nextIsLower && !plural) {
+				name += "_"
+			}
+		}
+		name += string(unicode.ToUpper(r))
+	}
+
+	return ENV_PREFIX + name
+}
+
+// applyEnvOverrides sets config fields from MB_* environment variables. A MB_*_FILE variable reads the value
+// from a file instead, for secrets mounted in containers. Lists are comma separated, other structured
+// settings are given as JSON.
+func applyEnvOverrides(config *MatterbuildConfig) *AppError {
+	value := reflect.ValueOf(config).Elem()
+	configType := value.Type()
+
+	for i := 0; i < configType.NumField(); i++ {
+		setting := configType.Field(i).Name
+		envName := EnvName(setting)
+
+		envValue, found := os.LookupEnv(envName)
+		if fileName, ok := os.LookupEnv(envName + ENV_FILE_SUFFIX); ok {
+			if found {
+				return NewError("Both "+envName+" and "+envName+ENV_FILE_SUFFIX+" are set", nil)
+			}
+
+			data, err := ioutil.ReadFile(fileName)
+			if err != nil {
+				return NewError("Unable to read "+envName+ENV_FILE_SUFFIX+" file="+fileName, err)
+			}
+			envValue = strings.TrimRight(string(data), "\r\n")
+			found = true
+		}
+
+		if !found {
+			continue
+		}
+
+		if err := setConfigField(value.Field(i), envValue); err != nil {
+			return NewError("Invalid value for "+envName, err)
+		}
+	}
+
+	return nil
+}
+
+func setConfigField(field reflect.Value, envValue string) error {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(envValue)
+	case reflect.Int, reflect.Int64:
+		i, err := strconv.ParseInt(envValue, 10, 64)
+		if err != nil {
+			return err
+		}
+		field.SetInt(i)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(envValue)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Slice:
+		if field.Type().Elem().Kind() == reflect.String && !strings.HasPrefix(strings.TrimSpace(envValue), "[") {
+			var list []string
+			for _, item := range strings.Split(envValue, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					list = append(list, item)
+				}
+			}
+			field.Set(reflect.ValueOf(list))
+			return nil
+		}
+		fallthrough
+	default:
+		return json.Unmarshal([]byte(envValue), field.Addr().Interface())
+	}
+
+	return nil
+}
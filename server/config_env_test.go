@@ -0,0 +1,145 @@
+// Copyright (c) 2018-present Mattermost, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package server
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestEnvName(t *testing.T) {
+	for _, tc := range []struct {
+		setting  string
+		expected string
+	}{
+		{"JenkinsPassword", "MB_JENKINS_PASSWORD"},
+		{"ListenAddress", "MB_LISTEN_ADDRESS"},
+		{"JenkinsURL", "MB_JENKINS_URL"},
+		{"TLSCertFile", "MB_TLS_CERT_FILE"},
+		{"CIServerJobs", "MB_CI_SERVER_JOBS"},
+		{"LogMaxSizeMB", "MB_LOG_MAX_SIZE_MB"},
+		{"ReleaseArtifactURLTemplate", "MB_RELEASE_ARTIFACT_URL_TEMPLATE"},
+		{"AllowedSourceCIDRs", "MB_ALLOWED_SOURCE_CIDRS"},
+		{"ScopedTokens", "MB_SCOPED_TOKENS"},
+	} {
+		if got := EnvName(tc.setting); got != tc.expected {
+			t.Errorf("EnvName(%q) = %q, expected %q", tc.setting, got, tc.expected)
+		}
+	}
+}
+
+func TestSetConfigField(t *testing.T) {
+	var settings struct {
+		String   string
+		Int      int
+		Bool     bool
+		List     []string
+		Limit    *RateLimit
+		Limits   map[string]*RateLimit
+		Networks []*Repository
+	}
+	fields := reflect.ValueOf(&settings).Elem()
+
+	for _, tc := range []struct {
+		field    string
+		value    string
+		expected interface{}
+		invalid  bool
+	}{
+		{field: "String", value: "value with spaces", expected: "value with spaces"},
+		{field: "Int", value: "42", expected: 42},
+		{field: "Int", value: "-1", expected: -1},
+		{field: "Int", value: "forty", invalid: true},
+		{field: "Bool", value: "true", expected: true},
+		{field: "Bool", value: "0", expected: false},
+		{field: "Bool", value: "yes", invalid: true},
+		{field: "List", value: "a, b,,c ", expected: []string{"a", "b", "c"}},
+		{field: "List", value: `["a,b", "c"]`, expected: []string{"a,b", "c"}},
+		{field: "List", value: `["a"`, invalid: true},
+		{field: "Limit", value: `{"RequestsPerMinute": 10, "Burst": 5}`, expected: &RateLimit{RequestsPerMinute: 10, Burst: 5}},
+		{field: "Limit", value: "10", invalid: true},
+		{field: "Limits", value: `{"cut": {"RequestsPerMinute": 1}}`, expected: map[string]*RateLimit{"cut": {RequestsPerMinute: 1}}},
+		{field: "Networks", value: `[{"Owner": "mattermost", "Name": "mattermost-server"}]`, expected: []*Repository{{Owner: "mattermost", Name: "mattermost-server"}}},
+	} {
+		field := fields.FieldByName(tc.field)
+		field.Set(reflect.Zero(field.Type()))
+
+		err := setConfigField(field, tc.value)
+		if tc.invalid {
+			if err == nil {
+				t.Errorf("%v=%q: expected an error", tc.field, tc.value)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v=%q: unexpected error %v", tc.field, tc.value, err)
+			continue
+		}
+		if got := field.Interface(); !reflect.DeepEqual(got, tc.expected) {
+			t.Errorf("%v=%q: got %#v, expected %#v", tc.field, tc.value, got, tc.expected)
+		}
+	}
+}
+
+func setEnv(t *testing.T, name, value string) func() {
+	if err := os.Setenv(name, value); err != nil {
+		t.Fatal(err)
+	}
+	return func() { os.Unsetenv(name) }
+}
+
+func TestApplyEnvOverrides(t *testing.T) {
+	dir, err := ioutil.TempDir("", "matterbuild")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	secretFile := filepath.Join(dir, "password")
+	if err := ioutil.WriteFile(secretFile, []byte("s3cret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	defer setEnv(t, "MB_JENKINS_URL", "https://jenkins.example.com")()
+	defer setEnv(t, "MB_JENKINS_PASSWORD_FILE", secretFile)()
+	defer setEnv(t, "MB_ALLOWED_USERS", "user1,user2")()
+	defer setEnv(t, "MB_SHUTDOWN_TIMEOUT_SECONDS", "30")()
+
+	config := &MatterbuildConfig{JenkinsURL: "https://old.example.com", ReleaseJob: "release"}
+	if err := applyEnvOverrides(config); err != nil {
+		t.Fatal(err)
+	}
+	if config.JenkinsURL != "https://jenkins.example.com" || config.JenkinsPassword != "s3cret" || config.ShutdownTimeoutSeconds != 30 {
+		t.Errorf("the settings weren't overridden: %+v", config)
+	}
+	if !reflect.DeepEqual(config.AllowedUsers, []string{"user1", "user2"}) {
+		t.Errorf("got the users %v", config.AllowedUsers)
+	}
+	if config.ReleaseJob != "release" {
+		t.Errorf("expected the settings without a variable to be kept, got %q", config.ReleaseJob)
+	}
+
+	for _, tc := range []struct {
+		name  string
+		value string
+	}{
+		{"MB_JENKINS_PASSWORD", "both"},
+		{"MB_LOG_MAX_SIZE_MB", "big"},
+	} {
+		restore := setEnv(t, tc.name, tc.value)
+		if err := applyEnvOverrides(&MatterbuildConfig{}); err == nil {
+			t.Errorf("%v=%q: expected an error", tc.name, tc.value)
+		}
+		restore()
+	}
+
+	os.Unsetenv("MB_JENKINS_PASSWORD_FILE")
+	defer setEnv(t, "MB_JENKINS_PASSWORD_FILE", filepath.Join(dir, "missing"))()
+	if err := applyEnvOverrides(&MatterbuildConfig{}); err == nil {
+		t.Error("expected an error for a missing secret file")
+	}
+}
@@ -0,0 +1,175 @@
+// Copyright (c) 2018-present Mattermost, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package server
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// validConfig returns a config with all the required settings. It logs to stderr so loading it doesn't
+// create a log file next to the tests.
+func validConfig() *MatterbuildConfig {
+	return &MatterbuildConfig{
+		LogOutput:     LOG_OUTPUT_STDERR,
+		ListenAddress: ":8080",
+		JenkinsURL:    "https://jenkins.example.com",
+		ReleaseJob:    "release",
+		PreChecksJob:  "prechecks",
+		RCTestingJob:  "rctesting",
+		OSSServerJob:  "oss",
+		PreReleaseJob: "prerelease",
+		AllowedTokens: []string{"token"},
+		AllowedUsers:  []string{"user"},
+		Repositories:  []*Repository{{Owner: "mattermost", Name: "mattermost-server"}},
+	}
+}
+
+func TestConfigIsValid(t *testing.T) {
+	if err := validConfig().IsValid(); err != nil {
+		t.Fatalf("expected the config to be valid, got %v", err)
+	}
+
+	for _, tc := range []struct {
+		name    string
+		change  func(c *MatterbuildConfig)
+		problem string
+	}{
+		{"no listen address", func(c *MatterbuildConfig) { c.ListenAddress = "" }, "ListenAddress is required"},
+		{"no jenkins", func(c *MatterbuildConfig) { c.JenkinsURL = "" }, "JenkinsURL is required"},
+		{"bad jenkins url", func(c *MatterbuildConfig) { c.JenkinsURL = "jenkins" }, "JenkinsURL is not a valid URL"},
+		{"no release job", func(c *MatterbuildConfig) { c.ReleaseJob = "" }, "ReleaseJob is required"},
+		{"empty ci server job", func(c *MatterbuildConfig) { c.CIServerJobs = []string{"ci", ""} }, "CIServerJobs can't contain empty job names"},
+		{"no tokens", func(c *MatterbuildConfig) { c.AllowedTokens = nil }, "at least one of AllowedTokens or ScopedTokens"},
+		{"empty token", func(c *MatterbuildConfig) { c.AllowedTokens = []string{""} }, "AllowedTokens can't contain empty tokens"},
+		{"empty scoped token", func(c *MatterbuildConfig) { c.ScopedTokens = []*ScopedToken{{}} }, "ScopedTokens can't contain empty tokens"},
+		{"no users", func(c *MatterbuildConfig) { c.AllowedUsers = nil }, "AllowedUsers is required"},
+		{"repository without a name", func(c *MatterbuildConfig) { c.Repositories = []*Repository{{Owner: "mattermost"}} }, "Repositories[0] needs an Owner and a Name"},
+		{"mattermost without a token", func(c *MatterbuildConfig) { c.MattermostURL = "https://mattermost.example.com" }, "MattermostBotToken is required"},
+		{"certificate without a key", func(c *MatterbuildConfig) { c.TLSCertFile = "cert.pem" }, "TLSCertFile and TLSKeyFile must be set together"},
+		{"bad log level", func(c *MatterbuildConfig) { c.LogLevel = "verbose" }, "LogLevel must be one of"},
+		{"negative rate limit", func(c *MatterbuildConfig) { c.UserRateLimit = &RateLimit{RequestsPerMinute: -1} }, "UserRateLimit can't be negative"},
+		{"translation check without the job", func(c *MatterbuildConfig) {
+			c.PreflightChecks = []*PreflightCheck{{Type: PREFLIGHT_BRANCH}, {Type: PREFLIGHT_TRANSLATION}}
+		}, "PreflightChecks[1] needs the CheckTranslationServerJob"},
+		{"bad reminder hour", func(c *MatterbuildConfig) { c.CalendarReminderHour = 24 }, "CalendarReminderHour must be between 0 and 23"},
+	} {
+		c := validConfig()
+		tc.change(c)
+		if err := c.IsValid(); err == nil || !strings.Contains(err.Error(), tc.problem) {
+			t.Errorf("%v: expected %q, got %v", tc.name, tc.problem, err)
+		}
+	}
+
+	// All the problems are reported at once.
+	c := validConfig()
+	c.ListenAddress = ""
+	c.AllowedUsers = nil
+	if err := c.IsValid(); err == nil || !strings.Contains(err.Error(), "ListenAddress") || !strings.Contains(err.Error(), "AllowedUsers") {
+		t.Errorf("expected both problems, got %v", err)
+	}
+}
+
+func writeConfigFile(t *testing.T, fileName string, c *MatterbuildConfig) {
+	b, err := json.Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(fileName, b, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// waitForConfig waits until the active config has the release job, the watcher reloads it in the background.
+func waitForConfig(t *testing.T, releaseJob string) {
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if Cfg().ReleaseJob == releaseJob {
+			return
+		}
+	}
+	t.Fatalf("expected the config with the release job %v, got %v", releaseJob, Cfg().ReleaseJob)
+}
+
+func TestLoadConfigKeepsTheActiveConfig(t *testing.T) {
+	defer setTestConfig(t, &MatterbuildConfig{})()
+	fileName := filepath.Join(Cfg().DataDirectory, "config.json")
+
+	writeConfigFile(t, fileName, validConfig())
+	if err := LoadConfig(fileName); err != nil {
+		t.Fatal(err)
+	}
+	active := Cfg()
+
+	invalid := validConfig()
+	invalid.JenkinsURL = ""
+	writeConfigFile(t, fileName, invalid)
+	if err := LoadConfig(fileName); err == nil {
+		t.Error("expected the invalid config to be rejected")
+	}
+	if Cfg() != active {
+		t.Error("expected the previous config to stay active")
+	}
+
+	if err := ioutil.WriteFile(fileName, []byte("{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadConfig(fileName); err == nil || Cfg() != active {
+		t.Errorf("expected the malformed config to be rejected, got %v", err)
+	}
+}
+
+func TestWatchConfig(t *testing.T) {
+	defer setTestConfig(t, &MatterbuildConfig{})()
+	fileName := filepath.Join(Cfg().DataDirectory, "config.json")
+
+	writeConfigFile(t, fileName, validConfig())
+	if err := LoadConfig(fileName); err != nil {
+		t.Fatal(err)
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	watchConfig(fileName, 10*time.Millisecond, stop)
+
+	// A change on disk is picked up.
+	changed := validConfig()
+	changed.ReleaseJob = "release-changed"
+	writeConfigFile(t, fileName, changed)
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(fileName, later, later); err != nil {
+		t.Fatal(err)
+	}
+	waitForConfig(t, "release-changed")
+
+	// An invalid change is ignored.
+	invalid := validConfig()
+	invalid.ReleaseJob = ""
+	writeConfigFile(t, fileName, invalid)
+	later = later.Add(time.Minute)
+	if err := os.Chtimes(fileName, later, later); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if Cfg().ReleaseJob != "release-changed" {
+		t.Errorf("expected the invalid config to be ignored, got the release job %q", Cfg().ReleaseJob)
+	}
+
+	// SIGHUP reloads the file even if it didn't change.
+	sighup := validConfig()
+	sighup.ReleaseJob = "release-sighup"
+	writeConfigFile(t, fileName, sighup)
+	if err := os.Chtimes(fileName, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if err := syscall.Kill(os.Getpid(), syscall.SIGHUP); err != nil {
+		t.Fatal(err)
+	}
+	waitForConfig(t, "release-sighup")
+}
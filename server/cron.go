@@ -0,0 +1,174 @@
+// Copyright (c) 2018-present Mattermost, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package server
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CronSchedule is a parsed cron expression with the usual five fields: minute, hour, day of month,
+// month and day of week. Fields accept *, numbers, ranges (1-5), lists (1,15) and steps (*/15, 0-30/10).
+// The @hourly, @daily, @weekly and @monthly shortcuts are also accepted.
+type CronSchedule struct {
+	expression string
+	minutes    []bool
+	hours      []bool
+	days       []bool
+	months     []bool
+	weekdays   []bool
+	// Like in cron, when both the day of month and the day of week are restricted, either of them
+	// matching is enough.
+	anyDay     bool
+	anyWeekday bool
+}
+
+var cronShortcuts = map[string]string{
+	"@hourly":  "0 * * * *",
+	"@daily":   "0 0 * * *",
+	"@weekly":  "0 0 * * 0",
+	"@monthly": "0 0 1 * *",
+}
+
+type cronField struct {
+	name     string
+	min, max int
+}
+
+var cronFields = []cronField{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+// ParseCron parses a cron expression.
+func ParseCron(expression string) (*CronSchedule, error) {
+	expanded := expression
+	if shortcut, ok := cronShortcuts[strings.TrimSpace(expression)]; ok {
+		expanded = shortcut
+	}
+
+	fields := strings.Fields(expanded)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("cron expression %q must have 5 fields: minute hour day-of-month month day-of-week", expression)
+	}
+
+	parsed := make([][]bool, len(fields))
+	for i, field := range fields {
+		values, err := parseCronField(field, cronFields[i])
+		if err != nil {
+			return nil, err
+		}
+		parsed[i] = values
+	}
+
+	// Sunday is both 0 and 7.
+	parsed[4][0] = parsed[4][0] || parsed[4][7]
+
+	return &CronSchedule{
+		expression: strings.Join(fields, " "),
+		minutes:    parsed[0],
+		hours:      parsed[1],
+		days:       parsed[2],
+		months:     parsed[3],
+		weekdays:   parsed[4][:7],
+		anyDay:     strings.HasPrefix(fields[2], "*"),
+		anyWeekday: strings.HasPrefix(fields[4], "*"),
+	}, nil
+}
+
+func parseCronField(field string, spec cronField) ([]bool, error) {
+	values := make([]bool, spec.max+1)
+
+	for _, part := range strings.Split(field, ",") {
+		rangePart := part
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			if step, err = strconv.Atoi(part[i+1:]); err != nil || step < 1 {
+				return nil, fmt.Errorf("bad step in %v field %q", spec.name, field)
+			}
+			rangePart = part[:i]
+		}
+
+		start, end := spec.min, spec.max
+		if rangePart != "*" {
+			bounds := strings.SplitN(rangePart, "-", 2)
+			var err error
+			if start, err = strconv.Atoi(bounds[0]); err != nil {
+				return nil, fmt.Errorf("bad value in %v field %q", spec.name, field)
+			}
+			end = start
+			if len(bounds) == 2 {
+				if end, err = strconv.Atoi(bounds[1]); err != nil {
+					return nil, fmt.Errorf("bad range in %v field %q", spec.name, field)
+				}
+			} else if step > 1 {
+				// 5/15 means from 5 to the max every 15
+				end = spec.max
+			}
+		}
+
+		if start < spec.min || end > spec.max || start > end {
+			return nil, fmt.Errorf("%v field %q must be between %v and %v", spec.name, field, spec.min, spec.max)
+		}
+
+		for value := start; value <= end; value += step {
+			values[value] = true
+		}
+	}
+
+	return values, nil
+}
+
+func (c *CronSchedule) String() string {
+	return c.expression
+}
+
+func (c *CronSchedule) dayMatches(t time.Time) bool {
+	day := c.days[t.Day()]
+	weekday := c.weekdays[int(t.Weekday())]
+	if !c.anyDay && !c.anyWeekday {
+		return day || weekday
+	}
+	return day && weekday
+}
+
+// Matches reports if the schedule runs at the minute of t.
+func (c *CronSchedule) Matches(t time.Time) bool {
+	return c.minutes[t.Minute()] && c.hours[t.Hour()] && c.months[int(t.Month())] && c.dayMatches(t)
+}
+
+// Next returns the first time after t the schedule runs, or the zero time if it never does (e.g. February 30th).
+func (c *CronSchedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+
+	// Five years is enough to find a February 29th.
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if !c.months[int(t.Month())] {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !c.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !c.hours[t.Hour()] {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if !c.minutes[t.Minute()] {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+
+	return time.Time{}
+}
@@ -0,0 +1,103 @@
+// Copyright (c) 2018-present Mattermost, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronMatches(t *testing.T) {
+	// 2018-11-04 is a Sunday, 2018-11-15 a Thursday.
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2018, 11, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	for _, tc := range []struct {
+		cron     string
+		t        time.Time
+		expected bool
+	}{
+		{"*/15 * * * *", at(5, 10, 0), true},
+		{"*/15 * * * *", at(5, 10, 45), true},
+		{"*/15 * * * *", at(5, 10, 5), false},
+		{"5/15 * * * *", at(5, 10, 5), true},
+		{"5/15 * * * *", at(5, 10, 50), true},
+		{"5/15 * * * *", at(5, 10, 0), false},
+		{"0-30/10 * * * *", at(5, 10, 30), true},
+		{"0-30/10 * * * *", at(5, 10, 20), true},
+		{"0-30/10 * * * *", at(5, 10, 40), false},
+		{"0 0 1,15 * *", at(15, 0, 0), true},
+		{"0 0 1,15 * *", at(14, 0, 0), false},
+		{"0 6 * * 7", at(4, 6, 0), true},
+		{"0 6 * * 0", at(4, 6, 0), true},
+		{"0 6 * * 1-5", at(4, 6, 0), false},
+		{"0 6 * * 1-5", at(5, 6, 0), true},
+		// When both days are restricted, either one matching is enough.
+		{"0 0 1 * 4", at(15, 0, 0), true},
+		{"0 0 1 * 4", at(1, 0, 0), true},
+		{"0 0 1 * 4", at(5, 0, 0), false},
+		// When only one is restricted, it must match.
+		{"0 0 1 * *", at(15, 0, 0), false},
+		{"0 0 * 12 *", at(15, 0, 0), false},
+		{"@daily", at(5, 0, 0), true},
+		{"@hourly", at(5, 7, 1), false},
+	} {
+		cron, err := ParseCron(tc.cron)
+		if err != nil {
+			t.Errorf("%q: %v", tc.cron, err)
+			continue
+		}
+		if got := cron.Matches(tc.t); got != tc.expected {
+			t.Errorf("%q: Matches(%v) = %v, expected %v", tc.cron, tc.t, got, tc.expected)
+		}
+	}
+}
+
+func TestParseCronErrors(t *testing.T) {
+	for _, cron := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"30-10 * * * *",
+		"*/0 * * * *",
+		"a * * * *",
+		"@yearly",
+	} {
+		if _, err := ParseCron(cron); err == nil {
+			t.Errorf("%q: expected an error", cron)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	for _, tc := range []struct {
+		cron     string
+		from     time.Time
+		expected time.Time
+	}{
+		{"*/15 * * * *", time.Date(2018, 11, 5, 10, 7, 30, 0, time.UTC), time.Date(2018, 11, 5, 10, 15, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2018, 11, 5, 10, 15, 0, 0, time.UTC), time.Date(2018, 11, 5, 10, 30, 0, 0, time.UTC)},
+		{"0 6 * * 1-5", time.Date(2018, 11, 2, 7, 0, 0, 0, time.UTC), time.Date(2018, 11, 5, 6, 0, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2018, 11, 15, 0, 0, 0, 0, time.UTC), time.Date(2018, 12, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2018, 12, 15, 0, 0, 0, 0, time.UTC), time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"30 23 31 * *", time.Date(2018, 11, 1, 0, 0, 0, 0, time.UTC), time.Date(2018, 12, 31, 23, 30, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2018, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2020, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Date(2018, 3, 1, 0, 0, 0, 0, time.UTC), time.Time{}},
+	} {
+		cron, err := ParseCron(tc.cron)
+		if err != nil {
+			t.Errorf("%q: %v", tc.cron, err)
+			continue
+		}
+		if got := cron.Next(tc.from); !got.Equal(tc.expected) {
+			t.Errorf("%q: Next(%v) = %v, expected %v", tc.cron, tc.from, got, tc.expected)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright (c) 2018-present Mattermost, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/mattermost/matterbuild/version"
+)
+
+const (
+	NEXT_RC    = "rc"
+	NEXT_FINAL = "final"
+	NEXT_PATCH = "patch"
+
+	CUT_PROPOSAL_EXPIRY = 10 * time.Minute
+)
+
+// cutProposal is a version suggested by `cut --next` waiting for the user to confirm it.
+type cutProposal struct {
+	Version   *version.Version
+	Backport  bool
+	DryRun    bool
+	ExpiresAt time.Time
+}
+
+var cutProposals = map[string]*cutProposal{}
+var cutProposalsLock sync.Mutex
+
+// latestReleaseLine returns the release line of the latest tagged version, e.g. 5.1.
+func latestReleaseLine(versions []*version.Version) (string, *AppError) {
+	latest := version.Latest(versions)
+	if latest == nil {
+		return "", NewError("I couldn't find any version tags in the configured repositories.", nil)
+	}
+	return latest.ShortRelease(), nil
+}
+
+// nextVersion works out the next version of the given kind from the tagged versions. The release line
+// (e.g. 5.1) defaults to the latest one, in which case the next RC after a final release starts the
+// next release line. Only the branchVersions, the versions tagged on the release branch of the line, are used
+// for the current version. It also reports if the version is a backport, i.e. if there is a newer release line.
+func nextVersion(versions []*version.Version, branchVersions []*version.Version, kind string, releaseLine string) (*version.Version, bool, *AppError) {
+	latestLine, err := latestReleaseLine(versions)
+	if err != nil {
+		return nil, false, err
+	}
+
+	defaultLine := releaseLine == ""
+	if defaultLine {
+		releaseLine = latestLine
+	}
+
+	var onLine []*version.Version
+	for _, v := range branchVersions {
+		if v.ShortRelease() == releaseLine {
+			onLine = append(onLine, v)
+		}
+	}
+	current := version.Latest(onLine)
+	if current == nil {
+		return nil, false, NewError("I couldn't find any version tags on the release-"+releaseLine+" branch.", nil)
+	}
+	backport := current.ShortRelease() != latestLine
+
+	switch kind {
+	case NEXT_RC:
+		if current.IsFinal() && defaultLine {
+			// The latest release line shipped, the next RC starts the next one.
+			next := current.NextMinor()
+			next.Suffix = version.SUFFIX_RC
+			next.SuffixNumber = 1
+			return next, false, nil
+		}
+		return current.NextRC(), backport, nil
+	case NEXT_FINAL:
+		if current.IsFinal() {
+			return nil, false, NewError("The latest version of "+releaseLine+" is already final ("+current.String()+"). Did you mean `--next patch`?", nil)
+		}
+		return current.Final(), backport, nil
+	case NEXT_PATCH:
+		if !current.IsFinal() {
+			return nil, false, NewError("The latest version of "+releaseLine+" ("+current.String()+") isn't final yet. Did you mean `--next rc` or `--next final`?", nil)
+		}
+		return current.NextPatch(), backport, nil
+	}
+
+	return nil, false, NewError("Unknown version kind `"+kind+"`, it should be one of rc, final or patch.", nil)
+}
+
+func proposeNextCutCommandF(kind string, releaseLine string, w http.ResponseWriter, slashCommand *MMSlashCommand, dryrun bool) error {
+	versions, err := listConfiguredVersionTags(slashCommand.Context())
+	if err != nil {
+		WriteErrorResponse(w, err)
+		return nil
+	}
+
+	line := releaseLine
+	if line == "" {
+		if line, err = latestReleaseLine(versions); err != nil {
+			WriteErrorResponse(w, err)
+			return nil
+		}
+	}
+
+	branchVersions, err := listReleaseBranchVersions(slashCommand.Context(), line)
+	if err != nil {
+		WriteErrorResponse(w, err)
+		return nil
+	}
+
+	next, backport, err := nextVersion(versions, branchVersions, kind, releaseLine)
+	if err != nil {
+		WriteErrorResponse(w, err)
+		return nil
+	}
+
+	cutProposalsLock.Lock()
+	cutProposals[slashCommand.UserId] = &cutProposal{
+		Version:   next,
+		Backport:  backport,
+		DryRun:    dryrun,
+		ExpiresAt: time.Now().Add(CUT_PROPOSAL_EXPIRY),
+	}
+	cutProposalsLock.Unlock()
+
+	msg := fmt.Sprintf("The next version is **%v**", next)
+	if backport {
+		msg += " (backport, there is a newer release line)"
+	}
+	if dryrun {
+		msg += " as a dry run"
+	}
+	msg += fmt.Sprintf(".\nRun `/matterbuild cut --confirm` within %v minutes to cut it.", int(CUT_PROPOSAL_EXPIRY.Minutes()))
+	WriteEnrichedResponse(w, "Cut Release", msg, "#0060aa", EPHEMERAL)
+	return nil
+}
+
+func confirmNextCutCommandF(w http.ResponseWriter, slashCommand *MMSlashCommand) error {
+	cutProposalsLock.Lock()
+	proposal := cutProposals[slashCommand.UserId]
+	delete(cutProposals, slashCommand.UserId)
+	cutProposalsLock.Unlock()
+
+	if proposal == nil || time.Now().After(proposal.ExpiresAt) {
+		WriteErrorResponse(w, NewError("There is no version waiting for your confirmation. Run `/matterbuild cut --next [rc|final|patch]` first.", nil))
+		return nil
+	}
+
+	return cutVersion(proposal.Version, w, slashCommand, proposal.Backport, proposal.DryRun)
+}
@@ -0,0 +1,87 @@
+// Copyright (c) 2018-present Mattermost, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package server
+
+import (
+	"testing"
+
+	"github.com/mattermost/matterbuild/version"
+)
+
+func parseVersions(t *testing.T, list ...string) []*version.Version {
+	var versions []*version.Version
+	for _, s := range list {
+		v, err := version.Parse(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		versions = append(versions, v)
+	}
+	return versions
+}
+
+func TestNextVersion(t *testing.T) {
+	tags := parseVersions(t, "5.0.0", "5.0.1", "5.1.0-rc1", "5.1.0-rc2", "5.1.0", "5.2.0-rc1", "5.0.2-rc1")
+
+	for _, tc := range []struct {
+		name     string
+		branch   []string
+		kind     string
+		line     string
+		expected string
+		backport bool
+		invalid  bool
+	}{
+		{name: "next rc", branch: []string{"5.2.0-rc1"}, kind: NEXT_RC, expected: "5.2.0-rc2"},
+		{name: "final", branch: []string{"5.2.0-rc1"}, kind: NEXT_FINAL, expected: "5.2.0"},
+		{name: "patch of an rc", branch: []string{"5.2.0-rc1"}, kind: NEXT_PATCH, invalid: true},
+		{name: "backport rc", branch: []string{"5.0.0", "5.0.1"}, kind: NEXT_RC, line: "5.0", expected: "5.0.2-rc1", backport: true},
+		{name: "backport patch", branch: []string{"5.0.0", "5.0.1"}, kind: NEXT_PATCH, line: "5.0", expected: "5.0.2", backport: true},
+		{name: "rc on the branch", branch: []string{"5.0.0", "5.0.1", "5.0.2-rc1"}, kind: NEXT_RC, line: "5.0", expected: "5.0.2-rc2", backport: true},
+		{name: "rc tagged off the branch", branch: []string{"5.0.0", "5.0.1"}, kind: NEXT_FINAL, line: "5.0", invalid: true},
+		{name: "tags of other lines on the branch", branch: []string{"5.1.0", "5.2.0-rc1"}, kind: NEXT_RC, line: "5.1", expected: "5.1.1-rc1", backport: true},
+		{name: "no tags on the branch", kind: NEXT_RC, invalid: true},
+		{name: "unknown kind", branch: []string{"5.2.0-rc1"}, kind: "beta", invalid: true},
+	} {
+		next, backport, err := nextVersion(tags, parseVersions(t, tc.branch...), tc.kind, tc.line)
+		if tc.invalid {
+			if err == nil {
+				t.Errorf("%v: expected an error, got %v", tc.name, next)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: unexpected error %v", tc.name, err)
+			continue
+		}
+		if next.String() != tc.expected || backport != tc.backport {
+			t.Errorf("%v: got %v (backport %v), expected %v (backport %v)", tc.name, next, backport, tc.expected, tc.backport)
+		}
+	}
+
+	// After a final release, the next rc starts the next release line.
+	next, _, err := nextVersion(parseVersions(t, "5.1.0"), parseVersions(t, "5.1.0"), NEXT_RC, "")
+	if err != nil || next.String() != "5.2.0-rc1" {
+		t.Errorf("expected 5.2.0-rc1 after 5.1.0, got %v (err=%v)", next, err)
+	}
+
+	if _, _, err := nextVersion(nil, nil, NEXT_RC, ""); err == nil {
+		t.Error("expected an error without tags")
+	}
+}
+
+func TestCutFlagsAreExclusive(t *testing.T) {
+	defer setTestConfig(t, &MatterbuildConfig{})()
+
+	for _, text := range []string{
+		"cut --confirm --next rc",
+		"cut --confirm --release 5.1",
+		"cut --confirm 5.1.0",
+		"cut --next rc 5.1.0",
+	} {
+		if err := executeCommand(newBufferedResponseWriter(), &MMSlashCommand{Command: "/matterbuild", Text: text}); err == nil {
+			t.Errorf("%q: expected an error", text)
+		}
+	}
+}
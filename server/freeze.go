@@ -0,0 +1,331 @@
+// Copyright (c) 2018-present Mattermost, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	FREEZE_FILE = "freeze.json"
+
+	FREEZE_DATE_FORMAT      = "2006-01-02"
+	FREEZE_DATE_TIME_FORMAT = "2006-01-02 15:04"
+	// Slash command arguments can't contain spaces.
+	FREEZE_DATE_TIME_ARG_FORMAT = "2006-01-02T15:04"
+)
+
+// Commands blocked during a freeze unless a release admin adds --force.
+var frozenCommands = []string{"cut", "setci", "merge"}
+
+// FreezeWindow is a period when releases are frozen. It is either dated, from Start to End given as
+// 2006-01-02 (whole days, End included) or 2006-01-02 15:04, or recurring on the given Weekdays,
+// e.g. Saturday and Sunday. Times are in the Timezone, or the local time of the server.
+type FreezeWindow struct {
+	Name     string
+	Start    string
+	End      string
+	Weekdays []string
+	Timezone string
+}
+
+// AdHocFreeze is a freeze started with `/matterbuild freeze start`, it lasts until Until or until it is ended.
+type AdHocFreeze struct {
+	Reason    string
+	UserId    string
+	Username  string
+	StartedAt time.Time
+	Until     time.Time
+}
+
+func (fw *FreezeWindow) location() (*time.Location, error) {
+	if fw.Timezone == "" {
+		return time.Local, nil
+	}
+	return time.LoadLocation(fw.Timezone)
+}
+
+// bounds returns the start and end of a dated window.
+func (fw *FreezeWindow) bounds() (time.Time, time.Time, error) {
+	loc, err := fw.location()
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	start, err := parseFreezeTime(fw.Start, loc, false)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	end, err := parseFreezeTime(fw.End, loc, true)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	if !end.After(start) {
+		return time.Time{}, time.Time{}, fmt.Errorf("End must be after Start")
+	}
+
+	return start, end, nil
+}
+
+// parseFreezeTime parses a date or a date and time. A date used as the end of a window includes the whole day.
+func parseFreezeTime(value string, loc *time.Location, isEnd bool) (time.Time, error) {
+	for _, format := range []string{FREEZE_DATE_TIME_FORMAT, FREEZE_DATE_TIME_ARG_FORMAT} {
+		if t, err := time.ParseInLocation(format, value, loc); err == nil {
+			return t, nil
+		}
+	}
+
+	t, err := time.ParseInLocation(FREEZE_DATE_FORMAT, value, loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("bad time %q, use 2006-01-02 or 2006-01-02 15:04", value)
+	}
+	if isEnd {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+// IsValid checks the window, it is used when the config is loaded.
+func (fw *FreezeWindow) IsValid() error {
+	if len(fw.Weekdays) > 0 {
+		if fw.Start != "" || fw.End != "" {
+			return fmt.Errorf("Weekdays can't be used with Start and End")
+		}
+		for _, weekday := range fw.Weekdays {
+			if _, ok := parseWeekday(weekday); !ok {
+				return fmt.Errorf("unknown weekday %q", weekday)
+			}
+		}
+		_, err := fw.location()
+		return err
+	}
+
+	_, _, err := fw.bounds()
+	return err
+}
+
+func parseWeekday(name string) (time.Weekday, bool) {
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if strings.EqualFold(day.String(), name) {
+			return day, true
+		}
+	}
+	return time.Sunday, false
+}
+
+// Contains reports if t is in the window.
+func (fw *FreezeWindow) Contains(t time.Time) bool {
+	loc, err := fw.location()
+	if err != nil {
+		return false
+	}
+
+	if len(fw.Weekdays) > 0 {
+		for _, weekday := range fw.Weekdays {
+			if day, ok := parseWeekday(weekday); ok && t.In(loc).Weekday() == day {
+				return true
+			}
+		}
+		return false
+	}
+
+	start, end, err := fw.bounds()
+	if err != nil {
+		return false
+	}
+	return !t.Before(start) && t.Before(end)
+}
+
+func (fw *FreezeWindow) String() string {
+	var when string
+	if len(fw.Weekdays) > 0 {
+		when = "every " + strings.Join(fw.Weekdays, ", ")
+	} else {
+		when = fw.Start + " to " + fw.End
+	}
+	if fw.Timezone != "" {
+		when += " (" + fw.Timezone + ")"
+	}
+	return "**" + fw.Name + "** " + when
+}
+
+func (f *AdHocFreeze) String() string {
+	s := fmt.Sprintf("**%v** started by @%v on %v", f.Reason, f.Username, f.StartedAt.Format(FREEZE_DATE_TIME_FORMAT+" MST"))
+	if !f.Until.IsZero() {
+		s += " until " + f.Until.Format(FREEZE_DATE_TIME_FORMAT+" MST")
+	}
+	return s
+}
+
+// currentAdHocFreeze returns the ad-hoc freeze if there is one in progress.
+func currentAdHocFreeze() *AdHocFreeze {
+	var freeze *AdHocFreeze
+	if _, err := loadJSON(FREEZE_FILE, &freeze); err != nil {
+		LogError("[currentAdHocFreeze] Unable to load the freeze err=" + err.Error())
+		return nil
+	}
+
+	if freeze != nil && !freeze.Until.IsZero() && time.Now().After(freeze.Until) {
+		return nil
+	}
+	return freeze
+}
+
+// setAdHocFreeze replaces the ad-hoc freeze, nil ends it. It returns the freeze in progress before.
+func setAdHocFreeze(freeze *AdHocFreeze) (*AdHocFreeze, *AppError) {
+	var previous *AdHocFreeze
+	var stored *AdHocFreeze
+	err := updateJSON(FREEZE_FILE, &stored, func() (bool, *AppError) {
+		if stored != nil && (stored.Until.IsZero() || time.Now().Before(stored.Until)) {
+			previous = stored
+		}
+		if stored == nil && freeze == nil {
+			return false, nil
+		}
+		stored = freeze
+		return true, nil
+	})
+	return previous, err
+}
+
+// activeFreezes describes the ad-hoc freeze and the configured windows in effect at t.
+func activeFreezes(t time.Time) []string {
+	var active []string
+	if freeze := currentAdHocFreeze(); freeze != nil {
+		active = append(active, freeze.String())
+	}
+	for _, window := range Cfg().FreezeWindows {
+		if window.Contains(t) {
+			active = append(active, window.String())
+		}
+	}
+	return active
+}
+
+// checkFreeze blocks the frozen commands during a freeze, unless a release admin forces them.
+func checkFreeze(command *MMSlashCommand, cmd *cobra.Command) *AppError {
+	return checkFreezeOverride(command, cmd, isReleaseAdmin(command.UserId))
+}
+
+// checkFreezeOverride blocks the frozen commands during a freeze, canForce tells if --force overrides it.
+func checkFreezeOverride(command *MMSlashCommand, cmd *cobra.Command, canForce bool) *AppError {
+	if !containsOrEmpty(frozenCommands, topLevelCommand(cmd)) {
+		return nil
+	}
+
+	active := activeFreezes(time.Now())
+	if len(active) == 0 {
+		return nil
+	}
+
+	// The flags were parsed when the command was resolved, commands without --force can't be forced.
+	if force, _ := cmd.Flags().GetBool("force"); force {
+		if canForce {
+			LogInfoContext(command.Context(), "[checkFreeze] "+command.Username+" forced `"+command.Text+"` during the freeze: "+strings.Join(active, "; "))
+			return nil
+		}
+		return NewError("Only release admins can use --force during a freeze.", nil)
+	}
+
+	return NewError("Releases are frozen:\n- "+strings.Join(active, "\n- ")+"\nA release admin can override the freeze with `--force`.", nil)
+}
+
+// isReleaseManager reports if the user is one of the ReleaseUsers or ReleaseAdmins.
+func isReleaseManager(userId string) bool {
+	if isReleaseAdmin(userId) {
+		return true
+	}
+	for _, releaseUser := range Cfg().ReleaseUsers {
+		if releaseUser == userId {
+			return true
+		}
+	}
+	return false
+}
+
+func freezeStartCmdF(args []string, w http.ResponseWriter, slashCommand *MMSlashCommand, until string) error {
+	if !isReleaseManager(slashCommand.UserId) {
+		return NewError("Only release managers can start a freeze.", nil)
+	}
+
+	freeze := &AdHocFreeze{
+		Reason:    strings.Join(args, " "),
+		UserId:    slashCommand.UserId,
+		Username:  slashCommand.Username,
+		StartedAt: time.Now(),
+	}
+	if freeze.Reason == "" {
+		freeze.Reason = "Release freeze"
+	}
+
+	if until != "" {
+		untilTime, err := parseFreezeTime(until, time.Local, true)
+		if err != nil {
+			return NewError("Bad --until argument, use 2006-01-02 or 2006-01-02T15:04.", err)
+		}
+		freeze.Until = untilTime
+	}
+
+	if _, err := setAdHocFreeze(freeze); err != nil {
+		return err
+	}
+
+	LogInfoContext(slashCommand.Context(), "[freezeStartCmdF] Freeze started by "+slashCommand.Username+": "+freeze.Reason)
+	WriteEnrichedResponse(w, "Release Freeze", "Releases are frozen: "+freeze.String(), "#e20025", IN_CHANNEL)
+	return nil
+}
+
+func freezeEndCmdF(args []string, w http.ResponseWriter, slashCommand *MMSlashCommand) error {
+	if !isReleaseManager(slashCommand.UserId) {
+		return NewError("Only release managers can end a freeze.", nil)
+	}
+
+	freeze, err := setAdHocFreeze(nil)
+	if err != nil {
+		return err
+	}
+	if freeze == nil {
+		WriteEnrichedResponse(w, "Release Freeze", "There is no freeze in progress started with `freeze start`.", "#0060aa", EPHEMERAL)
+		return nil
+	}
+
+	LogInfoContext(slashCommand.Context(), "[freezeEndCmdF] Freeze ended by "+slashCommand.Username+": "+freeze.Reason)
+	msg := "Ended the freeze " + freeze.String() + "."
+	if active := activeFreezes(time.Now()); len(active) > 0 {
+		msg += "\nThese configured freeze windows are still in effect:\n- " + strings.Join(active, "\n- ")
+	}
+	WriteEnrichedResponse(w, "Release Freeze", msg, "#86c323", IN_CHANNEL)
+	return nil
+}
+
+func freezeListCmdF(args []string, w http.ResponseWriter, slashCommand *MMSlashCommand) error {
+	now := time.Now()
+
+	msg := ""
+	if active := activeFreezes(now); len(active) > 0 {
+		msg += "Releases are frozen now:\n- " + strings.Join(active, "\n- ") + "\n"
+	} else {
+		msg += "Releases are not frozen now.\n"
+	}
+
+	if len(Cfg().FreezeWindows) > 0 {
+		msg += "#### Freeze windows\n"
+		for _, window := range Cfg().FreezeWindows {
+			if len(window.Weekdays) == 0 {
+				if _, end, err := window.bounds(); err == nil && end.Before(now) {
+					continue
+				}
+			}
+			msg += "- " + window.String() + "\n"
+		}
+	}
+
+	WriteEnrichedResponse(w, "Release Freeze", msg, "#0060aa", EPHEMERAL)
+	return nil
+}
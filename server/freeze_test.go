@@ -0,0 +1,124 @@
+// Copyright (c) 2018-present Mattermost, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFreezeWindowContains(t *testing.T) {
+	toronto, err := time.LoadLocation("America/Toronto")
+	if err != nil {
+		t.Skip("no timezone database")
+	}
+
+	weekend := &FreezeWindow{Name: "Weekend", Weekdays: []string{"Saturday", "sunday"}, Timezone: "America/Toronto"}
+	holidays := &FreezeWindow{Name: "Holidays", Start: "2018-12-22", End: "2019-01-02", Timezone: "America/Toronto"}
+	maintenance := &FreezeWindow{Name: "Maintenance", Start: "2018-11-05 09:00", End: "2018-11-05 17:30", Timezone: "America/Toronto"}
+
+	for _, tc := range []struct {
+		name     string
+		window   *FreezeWindow
+		t        time.Time
+		expected bool
+	}{
+		{name: "saturday", window: weekend, t: time.Date(2018, 11, 3, 12, 0, 0, 0, toronto), expected: true},
+		{name: "sunday in lowercase", window: weekend, t: time.Date(2018, 11, 4, 23, 59, 0, 0, toronto), expected: true},
+		{name: "monday", window: weekend, t: time.Date(2018, 11, 5, 0, 0, 0, 0, toronto)},
+		{name: "saturday in the timezone of the window", window: weekend, t: time.Date(2018, 11, 3, 2, 0, 0, 0, time.UTC)},
+		{name: "first day", window: holidays, t: time.Date(2018, 12, 22, 0, 0, 0, 0, toronto), expected: true},
+		{name: "last day is included", window: holidays, t: time.Date(2019, 1, 2, 23, 59, 0, 0, toronto), expected: true},
+		{name: "before", window: holidays, t: time.Date(2018, 12, 21, 23, 59, 0, 0, toronto)},
+		{name: "after", window: holidays, t: time.Date(2019, 1, 3, 0, 0, 0, 0, toronto)},
+		{name: "during the hours", window: maintenance, t: time.Date(2018, 11, 5, 9, 0, 0, 0, toronto), expected: true},
+		{name: "end time is excluded", window: maintenance, t: time.Date(2018, 11, 5, 17, 30, 0, 0, toronto)},
+		{name: "before the hours", window: maintenance, t: time.Date(2018, 11, 5, 8, 59, 0, 0, toronto)},
+	} {
+		if got := tc.window.Contains(tc.t); got != tc.expected {
+			t.Errorf("%v: Contains(%v) = %v, expected %v", tc.name, tc.t, got, tc.expected)
+		}
+	}
+}
+
+func TestFreezeWindowIsValid(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		window  FreezeWindow
+		invalid bool
+	}{
+		{name: "weekdays", window: FreezeWindow{Weekdays: []string{"Friday"}}},
+		{name: "dates", window: FreezeWindow{Start: "2018-12-22", End: "2019-01-02"}},
+		{name: "one day", window: FreezeWindow{Start: "2018-12-25", End: "2018-12-25"}},
+		{name: "times", window: FreezeWindow{Start: "2018-12-22 18:00", End: "2018-12-23T06:00"}},
+		{name: "unknown weekday", window: FreezeWindow{Weekdays: []string{"Caturday"}}, invalid: true},
+		{name: "weekdays and dates", window: FreezeWindow{Weekdays: []string{"Friday"}, Start: "2018-12-22"}, invalid: true},
+		{name: "unknown timezone", window: FreezeWindow{Weekdays: []string{"Friday"}, Timezone: "Mars/Olympus"}, invalid: true},
+		{name: "missing end", window: FreezeWindow{Start: "2018-12-22"}, invalid: true},
+		{name: "bad date", window: FreezeWindow{Start: "22/12/2018", End: "2019-01-02"}, invalid: true},
+		{name: "end before start", window: FreezeWindow{Start: "2018-12-22 18:00", End: "2018-12-22 06:00"}, invalid: true},
+	} {
+		err := tc.window.IsValid()
+		if tc.invalid && err == nil {
+			t.Errorf("%v: expected an error", tc.name)
+		} else if !tc.invalid && err != nil {
+			t.Errorf("%v: unexpected error %v", tc.name, err)
+		}
+	}
+}
+
+func TestCheckFreeze(t *testing.T) {
+	defer setTestConfig(t, &MatterbuildConfig{
+		ReleaseUsers:  []string{"manager"},
+		ReleaseAdmins: []string{"admin"},
+	})()
+
+	if _, err := setAdHocFreeze(&AdHocFreeze{Reason: "Testing", Username: "manager", StartedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		text    string
+		userId  string
+		allowed bool
+	}{
+		{text: "cut 5.1.0-rc1", userId: "admin"},
+		{text: "cut --force 5.1.0-rc1", userId: "admin", allowed: true},
+		{text: "cut --force=true 5.1.0-rc1", userId: "admin", allowed: true},
+		{text: "cut --force=false 5.1.0-rc1", userId: "admin"},
+		{text: "cut --force 5.1.0-rc1", userId: "manager"},
+		{text: "setci --force release-5.1", userId: "admin", allowed: true},
+		{text: "merge --force 5.1.0", userId: "admin", allowed: true},
+		{text: "cut 5.1.0-rc1 --force", userId: "admin", allowed: true},
+		{text: "lock status", userId: "manager", allowed: true},
+		{text: "freeze list", userId: "manager", allowed: true},
+	} {
+		command := &MMSlashCommand{Command: "/matterbuild", Text: tc.text, UserId: tc.userId, Username: tc.userId}
+		cmd, err := resolveCommand(command)
+		if err != nil {
+			t.Fatalf("%q: %v", tc.text, err)
+		}
+
+		err = checkFreeze(command, cmd)
+		if tc.allowed && err != nil {
+			t.Errorf("%q by %v: unexpected error %v", tc.text, tc.userId, err)
+		} else if !tc.allowed && err == nil {
+			t.Errorf("%q by %v: expected the freeze to block it", tc.text, tc.userId)
+		}
+	}
+
+	// Ending the freeze, e.g. from another matterbuild process, is seen right away.
+	if err := saveJSON(FREEZE_FILE, nil); err != nil {
+		t.Fatal(err)
+	}
+	command := &MMSlashCommand{Command: "/matterbuild", Text: "cut 5.1.0-rc1", UserId: "manager"}
+	cmd, _ := resolveCommand(command)
+	if err := checkFreeze(command, cmd); err != nil {
+		t.Errorf("expected the freeze to be over, got %v", err)
+	}
+
+	if previous, err := setAdHocFreeze(nil); err != nil || previous != nil {
+		t.Errorf("expected no freeze to end, got %+v (err=%v)", previous, err)
+	}
+}
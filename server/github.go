@@ -0,0 +1,123 @@
+// Copyright (c) 2018-present Mattermost, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package server
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/go-github/github"
+	"github.com/mattermost/matterbuild/version"
+	"golang.org/x/oauth2"
+)
+
+func githubClient() *github.Client {
+	ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: Cfg().GithubAccessToken})
+	return github.NewClient(oauth2.NewClient(ctx, ts))
+}
+
+// versionTag is a tag of a release version, Name is the tag itself, e.g. v5.1.0.
+type versionTag struct {
+	Name    string
+	Version *version.Version
+}
+
+// listVersionTags returns the release versions tagged in the repository, tags that aren't versions are ignored.
+func listVersionTags(ctx context.Context, repository *Repository) ([]*version.Version, *AppError) {
+	tags, err := listTagsOfVersions(ctx, repository)
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []*version.Version
+	for _, tag := range tags {
+		versions = append(versions, tag.Version)
+	}
+	return versions, nil
+}
+
+func listTagsOfVersions(ctx context.Context, repository *Repository) ([]*versionTag, *AppError) {
+	var versions []*versionTag
+
+	opt := &github.ListOptions{PerPage: 100}
+	for {
+		tags, resp, err := githubClient().Repositories.ListTags(ctx, repository.Owner, repository.Name, opt)
+		if err != nil {
+			githubAPIErrorsTotal.Inc(repository.Owner+"/"+repository.Name, "list_tags")
+			return nil, NewError("Unable to list the tags of "+repository.Owner+"/"+repository.Name, err)
+		}
+
+		for _, tag := range tags {
+			if v, err := version.Parse(tag.GetName()); err == nil {
+				versions = append(versions, &versionTag{Name: tag.GetName(), Version: v})
+			}
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+
+	return versions, nil
+}
+
+// listConfiguredVersionTags returns the release versions tagged in any of the configured Repositories.
+func listConfiguredVersionTags(ctx context.Context) ([]*version.Version, *AppError) {
+	var versions []*version.Version
+	for _, repo := range Cfg().Repositories {
+		repoVersions, err := listVersionTags(ctx, repo)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, repoVersions...)
+	}
+	return versions, nil
+}
+
+// listReleaseBranchVersions returns the versions of the release line, e.g. 5.1, tagged on its release branch in
+// any of the configured Repositories. Tags of the line that the branch doesn't contain, like a tag pushed
+// from another branch by mistake, are left out.
+func listReleaseBranchVersions(ctx context.Context, releaseLine string) ([]*version.Version, *AppError) {
+	branch := "release-" + releaseLine
+
+	var versions []*version.Version
+	for _, repo := range Cfg().Repositories {
+		tags, err := listTagsOfVersions(ctx, repo)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, tag := range tags {
+			if tag.Version.ShortRelease() != releaseLine {
+				continue
+			}
+			reachable, err := isReachableFrom(ctx, repo, tag.Name, branch)
+			if err != nil {
+				return nil, err
+			}
+			if reachable {
+				versions = append(versions, tag.Version)
+			}
+		}
+	}
+	return versions, nil
+}
+
+// isReachableFrom reports if the branch contains the commit of ref. A missing branch contains nothing.
+func isReachableFrom(ctx context.Context, repository *Repository, ref, branch string) (bool, *AppError) {
+	name := repository.Owner + "/" + repository.Name
+	comparison, resp, err := githubClient().Repositories.CompareCommits(ctx, repository.Owner, repository.Name, ref, branch)
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			return false, nil
+		}
+		githubAPIErrorsTotal.Inc(name, "compare_commits")
+		return false, NewError("Unable to compare "+ref+" and "+branch+" in "+name, err)
+	}
+
+	// The branch is ahead of the ref, or at the same commit, when it contains it.
+	status := comparison.GetStatus()
+	return status == "ahead" || status == "identical", nil
+}
@@ -0,0 +1,116 @@
+// Copyright (c) 2018-present Mattermost, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/google/go-github/github"
+	"github.com/julienschmidt/httprouter"
+)
+
+// Branches created by createMergeAndPr are named merge-<release branch>-<timestamp>
+var mergeBranchRxp = regexp.MustCompile(`^merge-(.+)-[0-9]{14}$`)
+
+func isReleaseBranch(branch string) bool {
+	return strings.HasPrefix(branch, "release-")
+}
+
+func isConfiguredRepository(fullName string) bool {
+	for _, repo := range Cfg().Repositories {
+		if strings.EqualFold(repo.Owner+"/"+repo.Name, fullName) {
+			return true
+		}
+	}
+	return false
+}
+
+func githubHookHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if Cfg().GithubWebhookSecret == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	payload, err := github.ValidatePayload(r, []byte(Cfg().GithubWebhookSecret))
+	if err != nil {
+		LogError("[githubHookHandler] Invalid GitHub webhook signature err=" + err.Error())
+		http.Error(w, "Invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	eventType := github.WebHookType(r)
+	event, err := github.ParseWebHook(eventType, payload)
+	if err != nil {
+		LogError("[githubHookHandler] Unable to parse GitHub webhook type=" + eventType + " err=" + err.Error())
+		http.Error(w, "Unable to parse event", http.StatusBadRequest)
+		return
+	}
+
+	var title, msg, color string
+	switch e := event.(type) {
+	case *github.PullRequestEvent:
+		title, msg, color = pullRequestEventMessage(e)
+	case *github.PushEvent:
+		title, msg, color = pushEventMessage(e)
+	case *github.CreateEvent:
+		title, msg, color = createEventMessage(e)
+	}
+
+	if msg != "" {
+		LogInfo("[githubHookHandler] " + title + ": " + msg)
+		postNotification(Cfg().GithubNotificationChannelId, title, msg, color)
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func pullRequestEventMessage(e *github.PullRequestEvent) (string, string, string) {
+	if e.GetAction() != "closed" || !isConfiguredRepository(e.GetRepo().GetFullName()) {
+		return "", "", ""
+	}
+
+	pr := e.GetPullRequest()
+	matches := mergeBranchRxp.FindStringSubmatch(pr.GetHead().GetRef())
+	if matches == nil {
+		return "", "", ""
+	}
+
+	if pr.GetMerged() {
+		msg := fmt.Sprintf("[%v](%v) merging `%v` to `%v` in **%v** was merged by %v.", pr.GetTitle(), pr.GetHTMLURL(), matches[1], pr.GetBase().GetRef(), e.GetRepo().GetFullName(), pr.GetMergedBy().GetLogin())
+		return "Release Branch Merged", msg, "#86c323"
+	}
+
+	msg := fmt.Sprintf("[%v](%v) merging `%v` to `%v` in **%v** was closed without merging by %v.", pr.GetTitle(), pr.GetHTMLURL(), matches[1], pr.GetBase().GetRef(), e.GetRepo().GetFullName(), e.GetSender().GetLogin())
+	return "Release Branch Merge Closed", msg, "#e20025"
+}
+
+func pushEventMessage(e *github.PushEvent) (string, string, string) {
+	branch := strings.TrimPrefix(e.GetRef(), "refs/heads/")
+	if !isReleaseBranch(branch) || e.GetCreated() || !isConfiguredRepository(e.GetRepo().GetFullName()) {
+		return "", "", ""
+	}
+
+	if e.GetDeleted() {
+		msg := fmt.Sprintf("Branch `%v` was deleted from **%v** by %v.", branch, e.GetRepo().GetFullName(), e.GetPusher().GetName())
+		return "Release Branch Deleted", msg, "#e20025"
+	}
+
+	msg := fmt.Sprintf("%v pushed %v commit(s) to `%v` in **%v**. [Compare](%v)", e.GetPusher().GetName(), len(e.Commits), branch, e.GetRepo().GetFullName(), e.GetCompare())
+	if e.GetForced() {
+		msg += "\n**This was a force push.**"
+	}
+	return "Release Branch Updated", msg, "#0060aa"
+}
+
+func createEventMessage(e *github.CreateEvent) (string, string, string) {
+	if e.GetRefType() != "branch" || !isReleaseBranch(e.GetRef()) || !isConfiguredRepository(e.GetRepo().GetFullName()) {
+		return "", "", ""
+	}
+
+	msg := fmt.Sprintf("Branch `%v` was created in **%v** by %v.", e.GetRef(), e.GetRepo().GetFullName(), e.GetSender().GetLogin())
+	return "Release Branch Created", msg, "#0060aa"
+}
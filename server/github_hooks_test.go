@@ -0,0 +1,185 @@
+// Copyright (c) 2018-present Mattermost, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/github"
+)
+
+func githubSignature(secret, body string) string {
+	mac := hmac.New(sha1.New, []byte(secret))
+	mac.Write([]byte(body))
+	return "sha1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func postGithubEvent(event, body, signature string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/hooks/github", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("X-GitHub-Event", event)
+	if signature != "" {
+		r.Header.Set("X-Hub-Signature", signature)
+	}
+
+	w := httptest.NewRecorder()
+	githubHookHandler(w, r, nil)
+	return w
+}
+
+const githubCreateEvent = `{"ref": "release-5.1", "ref_type": "branch", "repository": {"full_name": "mattermost/mattermost-server"}, "sender": {"login": "releaser"}}`
+
+func TestGithubHookHandlerSignature(t *testing.T) {
+	mattermost := newFakeMattermost()
+	defer mattermost.Close()
+	defer setTestConfig(t, mattermost.config(&MatterbuildConfig{
+		GithubWebhookSecret:         "secret",
+		GithubNotificationChannelId: "channel",
+		Repositories:                []*Repository{{Owner: "mattermost", Name: "mattermost-server"}},
+	}))()
+
+	for _, tc := range []struct {
+		name      string
+		signature string
+		expected  int
+	}{
+		{name: "missing", expected: http.StatusUnauthorized},
+		{name: "malformed", signature: "sha1=not-hex", expected: http.StatusUnauthorized},
+		{name: "unknown algorithm", signature: "md5=" + strings.TrimPrefix(githubSignature("secret", githubCreateEvent), "sha1="), expected: http.StatusUnauthorized},
+		{name: "wrong secret", signature: githubSignature("other", githubCreateEvent), expected: http.StatusUnauthorized},
+		{name: "signed", signature: githubSignature("secret", githubCreateEvent), expected: http.StatusOK},
+	} {
+		if w := postGithubEvent("create", githubCreateEvent, tc.signature); w.Code != tc.expected {
+			t.Errorf("%v: got status %v, expected %v", tc.name, w.Code, tc.expected)
+		}
+	}
+
+	// Only the signed event is posted.
+	posts := mattermost.waitForPosts(t, 1)
+	if len(posts) != 1 || posts[0].ChannelId != "channel" || !strings.Contains(attachmentText(posts[0]), "`release-5.1` was created") {
+		t.Errorf("expected the signed event to be posted, got %+v", posts)
+	}
+
+	if w := postGithubEvent("create", "{", githubSignature("secret", "{")); w.Code != http.StatusBadRequest {
+		t.Errorf("expected a bad payload to be rejected, got status %v", w.Code)
+	}
+
+	// Without a secret the endpoint is disabled.
+	currentConfig.Store(&MatterbuildConfig{})
+	if w := postGithubEvent("create", githubCreateEvent, githubSignature("", githubCreateEvent)); w.Code != http.StatusNotFound {
+		t.Errorf("expected the endpoint to be disabled, got status %v", w.Code)
+	}
+}
+
+func TestGithubEventMessages(t *testing.T) {
+	defer setTestConfig(t, &MatterbuildConfig{
+		Repositories: []*Repository{{Owner: "mattermost", Name: "mattermost-server"}},
+	})()
+
+	for _, tc := range []struct {
+		name     string
+		event    string
+		payload  string
+		title    string
+		contains string
+	}{
+		{
+			name:     "merge pull request merged",
+			event:    "pull_request",
+			payload:  `{"action": "closed", "repository": {"full_name": "mattermost/mattermost-server"}, "pull_request": {"title": "Merge release-5.1", "merged": true, "head": {"ref": "merge-release-5.1-20180601120000"}, "base": {"ref": "master"}, "merged_by": {"login": "reviewer"}}}`,
+			title:    "Release Branch Merged",
+			contains: "merging `release-5.1` to `master` in **mattermost/mattermost-server** was merged by reviewer",
+		},
+		{
+			name:     "merge pull request closed",
+			event:    "pull_request",
+			payload:  `{"action": "closed", "repository": {"full_name": "mattermost/mattermost-server"}, "pull_request": {"merged": false, "head": {"ref": "merge-release-5.1-20180601120000"}, "base": {"ref": "master"}}, "sender": {"login": "reviewer"}}`,
+			title:    "Release Branch Merge Closed",
+			contains: "was closed without merging by reviewer",
+		},
+		{
+			name:    "other pull request",
+			event:   "pull_request",
+			payload: `{"action": "closed", "repository": {"full_name": "mattermost/mattermost-server"}, "pull_request": {"merged": true, "head": {"ref": "fix-thing"}}}`,
+		},
+		{
+			name:    "opened merge pull request",
+			event:   "pull_request",
+			payload: `{"action": "opened", "repository": {"full_name": "mattermost/mattermost-server"}, "pull_request": {"head": {"ref": "merge-release-5.1-20180601120000"}}}`,
+		},
+		{
+			name:     "push",
+			event:    "push",
+			payload:  `{"ref": "refs/heads/release-5.1", "compare": "https://github.com/compare", "commits": [{}, {}], "repository": {"full_name": "mattermost/mattermost-server"}, "pusher": {"name": "releaser"}}`,
+			title:    "Release Branch Updated",
+			contains: "releaser pushed 2 commit(s) to `release-5.1`",
+		},
+		{
+			name:     "force push",
+			event:    "push",
+			payload:  `{"ref": "refs/heads/release-5.1", "forced": true, "repository": {"full_name": "mattermost/mattermost-server"}, "pusher": {"name": "releaser"}}`,
+			title:    "Release Branch Updated",
+			contains: "This was a force push",
+		},
+		{
+			name:     "deleted",
+			event:    "push",
+			payload:  `{"ref": "refs/heads/release-5.1", "deleted": true, "repository": {"full_name": "mattermost/mattermost-server"}, "pusher": {"name": "releaser"}}`,
+			title:    "Release Branch Deleted",
+			contains: "`release-5.1` was deleted",
+		},
+		{
+			name:    "push to master",
+			event:   "push",
+			payload: `{"ref": "refs/heads/master", "repository": {"full_name": "mattermost/mattermost-server"}}`,
+		},
+		{
+			name:    "push creating the branch",
+			event:   "push",
+			payload: `{"ref": "refs/heads/release-5.1", "created": true, "repository": {"full_name": "mattermost/mattermost-server"}}`,
+		},
+		{
+			name:     "branch created",
+			event:    "create",
+			payload:  githubCreateEvent,
+			title:    "Release Branch Created",
+			contains: "`release-5.1` was created in **mattermost/mattermost-server** by releaser",
+		},
+		{
+			name:    "tag created",
+			event:   "create",
+			payload: `{"ref": "v5.1.0", "ref_type": "tag", "repository": {"full_name": "mattermost/mattermost-server"}}`,
+		},
+		{
+			name:    "other repository",
+			event:   "create",
+			payload: `{"ref": "release-5.1", "ref_type": "branch", "repository": {"full_name": "someone/fork"}}`,
+		},
+	} {
+		event, err := github.ParseWebHook(tc.event, []byte(tc.payload))
+		if err != nil {
+			t.Fatalf("%v: %v", tc.name, err)
+		}
+
+		var title, msg string
+		switch e := event.(type) {
+		case *github.PullRequestEvent:
+			title, msg, _ = pullRequestEventMessage(e)
+		case *github.PushEvent:
+			title, msg, _ = pushEventMessage(e)
+		case *github.CreateEvent:
+			title, msg, _ = createEventMessage(e)
+		}
+
+		if title != tc.title || !strings.Contains(msg, tc.contains) || (tc.contains == "" && msg != "") {
+			t.Errorf("%v: got %q %q, expected %q containing %q", tc.name, title, msg, tc.title, tc.contains)
+		}
+	}
+}
@@ -4,12 +4,15 @@
 package server
 
 import (
+	"context"
+	"fmt"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/beevik/etree"
 	"github.com/bndr/gojenkins"
+	"github.com/mattermost/matterbuild/version"
 )
 
 type JenkinsStatus struct {
@@ -19,35 +22,45 @@ type JenkinsStatus struct {
 }
 
 func getJenkins() (*gojenkins.Jenkins, *AppError) {
-	jenkins, err := gojenkins.CreateJenkins(Cfg.JenkinsURL, Cfg.JenkinsUsername, Cfg.JenkinsPassword).Init()
+	start := time.Now()
+	jenkins, err := gojenkins.CreateJenkins(Cfg().JenkinsURL, Cfg().JenkinsUsername, Cfg().JenkinsPassword).Init()
+	observeJenkinsCall("init", start, err)
 	if err != nil {
 		return nil, NewError("Unable to connect to jenkins!", err)
 	}
 	return jenkins, nil
 }
 
-func CutRelease(release string, rc string, isFirstMinorRelease bool, backportRelease bool, isDryRun bool) *AppError {
-	isRunning, err := IsCutReleaseRunning(Cfg.ReleaseJob)
+// CutRelease runs the pre-flight checks and starts the release jobs for the version in the background.
+// It returns the report of the pre-flight checks.
+func CutRelease(v *version.Version, backportRelease bool, isDryRun bool, slashCommand *MMSlashCommand) (string, *AppError) {
+	ctx := slashCommand.Context()
+
+	lock, err := AcquireReleaseLock(slashCommand)
 	if err != nil {
-		return err
+		return "", err
+	}
+
+	isRunning, err := IsCutReleaseRunning(ctx, Cfg().ReleaseJob)
+	if err != nil {
+		lock.Release()
+		return "", err
 	}
 	if isRunning {
-		return NewError("There is a release job running.", nil)
+		lock.Release()
+		return "", NewError("There is a release job running.", nil)
 	}
 
-	shortRelease := release[:len(release)-2]
-	releaseBranch := "release-" + shortRelease
-	fullRelease := release + "-" + rc
-	rcpart := rc
-	if rc == "" {
-		rcpart = ""
-		fullRelease = release
-	} else {
-		rcpart = "-" + rc
+	release := v.ReleasePart()
+	releaseBranch := v.ReleaseBranch()
+	fullRelease := v.String()
+	rcpart := ""
+	if !v.IsFinal() {
+		rcpart = "-" + v.SuffixPart()
 	}
 
 	isFirstMinorReleaseStr := "false"
-	if isFirstMinorRelease {
+	if v.IsFirstMinorRelease() {
 		isFirstMinorReleaseStr = "true"
 	}
 
@@ -61,83 +74,169 @@ func CutRelease(release string, rc string, isFirstMinorRelease bool, backportRel
 		isDotReleaseStr = "true"
 	}
 
-	if err := RunReleasePrechecks(); err != nil {
-		return err
+	progress := NewReleaseProgress(slashCommand.ChannelId, "Release "+fullRelease, []string{STEP_PRECHECKS, STEP_RELEASE_JOB, STEP_RC_TESTING, STEP_OSS_DEPLOY, STEP_CI_SERVERS, STEP_PRERELEASE})
+
+	record := StartReleaseRecord(fullRelease, v.SuffixPart(), backportRelease, isDryRun, slashCommand.UserId, slashCommand.Username)
+
+	progress.StepRunning(STEP_PRECHECKS, "")
+	report, err := RunReleasePrechecks(ctx, v)
+	if err != nil {
+		progress.StepFailed(STEP_PRECHECKS, report)
+		progress.SkipRemaining("Pre-checks failed")
+		record.Finish(RELEASE_FAILED, "Pre-checks failed")
+		lock.Release()
+		return "", err
 	}
+	progress.StepSucceeded(STEP_PRECHECKS, report)
+
+	op := StartOperation("Release "+fullRelease, slashCommand.UserId, LogFieldsFrom(ctx)["request_id"], map[string]string{
+		"version":  fullRelease,
+		"backport": isDotReleaseStr,
+		"dryrun":   isDryRunStr,
+	})
 
 	// We want to return so the user knows the build has started.
 	// Build jobs should report their own failure.
 	go func() {
-		result, err := RunJobWaitForResult(
-			Cfg.ReleaseJob,
+		defer op.Finish()
+		defer lock.Release()
+
+		// checkpoint stops the release chain between steps when matterbuild is shutting down.
+		checkpoint := func(step string) bool {
+			if !op.Checkpoint(step) {
+				progress.StepFailed(step, "Interrupted by a matterbuild shutdown, this step needs to be run manually.")
+				progress.SkipRemaining("Interrupted by a matterbuild shutdown")
+				record.Finish(RELEASE_INTERRUPTED, "Interrupted by a matterbuild shutdown before "+step)
+				return false
+			}
+			return true
+		}
+
+		// runStep starts a job of the release chain and records its build.
+		outcome := RELEASE_SUCCEEDED
+		runStep := func(step, job string, parameters map[string]string) {
+			number, err := runJobParameters(ctx, job, parameters)
+			if err != nil {
+				outcome = RELEASE_FAILED
+				record.AddBuild(step, job, number, "error: "+err.Error())
+			} else {
+				record.AddBuild(step, job, number, "started")
+			}
+			progress.StepResult(step, err)
+		}
+
+		progress.StepRunning(STEP_RELEASE_JOB, Cfg().ReleaseJob)
+		number, result, err := runJobWaitForBuild(
+			ctx,
+			Cfg().ReleaseJob,
 			map[string]string{
 				"MM_VERSION":             release,
 				"MM_RC":                  rcpart,
 				"IS_FIRST_MINOR_RELEASE": isFirstMinorReleaseStr,
 				"IS_DRY_RUN":             isDryRunStr,
 				"IS_DOT_RELEASE":         isDotReleaseStr,
+			},
+			func(notification *JenkinsNotification) {
+				if notification.Build.Phase == JENKINS_PHASE_STARTED {
+					progress.StepRunning(STEP_RELEASE_JOB, "["+Cfg().ReleaseJob+" #"+strconv.FormatInt(notification.Build.Number, 10)+"]("+notification.Build.FullUrl+")")
+				}
 			})
+		record.AddBuild(STEP_RELEASE_JOB, Cfg().ReleaseJob, number, result)
 		if err != nil || result != gojenkins.STATUS_SUCCESS {
-			LogError("Release Job failed. Version=" + fullRelease + " err= " + err.Error() + " Jenkins result= " + result)
+			if err != nil {
+				LogErrorContext(ctx, "Release Job failed. Version="+fullRelease+" err= "+err.Error())
+			} else {
+				LogErrorContext(ctx, "Release Job failed. Version="+fullRelease+" Jenkins result= "+result)
+			}
+			progress.StepFailed(STEP_RELEASE_JOB, "Jenkins result: "+result)
+			progress.SkipRemaining("The release job failed")
+			record.Finish(RELEASE_FAILED, "Release job failed")
 			return
 		} else {
 			// If Release was success trigger the Rctesting job to update
-			LogInfo("Release Job Status: " + result)
+			LogInfoContext(ctx, "Release Job Status: "+result)
+			progress.StepSucceeded(STEP_RELEASE_JOB, "Jenkins result: "+result)
 			if !backportRelease {
-				LogInfo("Will trigger Job: " + Cfg.RCTestingJob)
-				RunJobParameters(Cfg.RCTestingJob, map[string]string{"LONG_RELEASE": fullRelease})
+				if !checkpoint(STEP_RC_TESTING) {
+					return
+				}
+				LogInfoContext(ctx, "Will trigger Job: "+Cfg().RCTestingJob)
+				runStep(STEP_RC_TESTING, Cfg().RCTestingJob, map[string]string{"LONG_RELEASE": fullRelease})
 
 				//Deploy to OSS Server
-				LogInfo("Deploy MM to OSS Server")
-				RunJobParameters(Cfg.OSSServerJob, map[string]string{"MM_VERSION": fullRelease})
+				if !checkpoint(STEP_OSS_DEPLOY) {
+					return
+				}
+				LogInfoContext(ctx, "Deploy MM to OSS Server")
+				runStep(STEP_OSS_DEPLOY, Cfg().OSSServerJob, map[string]string{"MM_VERSION": fullRelease})
 				// Only update the CI servers and pre-release if this is the latest release
-				LogInfo("Setting CI Servers")
-				SetCIServerBranch(releaseBranch)
+				if !checkpoint(STEP_CI_SERVERS) {
+					return
+				}
+				LogInfoContext(ctx, "Setting CI Servers")
+				if err := SetCIServerBranch(ctx, releaseBranch); err != nil {
+					outcome = RELEASE_FAILED
+					progress.StepFailed(STEP_CI_SERVERS, err.Error())
+				} else {
+					progress.StepSucceeded(STEP_CI_SERVERS, "")
+				}
+
+				if !checkpoint(STEP_PRERELEASE) {
+					return
+				}
+				LogInfoContext(ctx, "Setting pre-release Server")
+				if err := SetPreReleaseTarget(ctx, fullRelease); err != nil {
+					progress.StepFailed(STEP_PRERELEASE, err.Error())
+					record.Finish(RELEASE_FAILED, "Unable to set the pre-release target")
+					return
+				}
+				LogInfoContext(ctx, "Running job to update pre-release")
+				runStep(STEP_PRERELEASE, Cfg().PreReleaseJob, nil)
+			} else {
+				progress.SkipRemaining("Backport release")
+			}
 
-				LogInfo("Setting pre-release Server")
-				SetPreReleaseTarget(fullRelease)
-				LogInfo("Running job to update pre-release")
-				RunJob(Cfg.PreReleaseJob)
+			if outcome == RELEASE_FAILED {
+				record.Finish(outcome, "Some steps after the release job failed")
+			} else {
+				record.Finish(outcome, "")
 			}
 		}
 	}()
 
-	return nil
+	return report, nil
 }
 
-func RunReleasePrechecks() *AppError {
-	if result, err := RunJobWaitForResult(Cfg.PreChecksJob, nil); err != nil || result != gojenkins.STATUS_SUCCESS {
-		LogError("[RunReleasePrechecks] Pre-checks failed! (Did you update the database upgrade code?) Result: "+result, err)
-		return NewError("Pre-checks failed! (Did you update the database upgrade code?) Result: "+result, err)
-	}
-
-	return nil
-}
-
-func getJob(name string) (*gojenkins.Job, *AppError) {
+func getJob(ctx context.Context, name string) (*gojenkins.Job, *AppError) {
 	jenkins, err := getJenkins()
 
 	if err != nil {
-		LogError("[getJob] Unable to get Jenkins ", err)
+		LogErrorContext(ctx, "[getJob] Unable to get Jenkins err="+err.Error())
 		return nil, err
 	}
 
-	if job, err := jenkins.GetJob(name); err != nil {
-		LogError("[getJob] Unable to get job: " + name + " err=" + err.Error())
-		return nil, NewError("Unable to get job", err)
-	} else {
-		return job, nil
+	start := time.Now()
+	job, err2 := jenkins.GetJob(name)
+	observeJenkinsCall("get_job", start, err2)
+	if err2 != nil {
+		LogErrorContext(ctx, "[getJob] Unable to get job: "+name+" err="+err2.Error())
+		return nil, NewError("Unable to get job", err2)
 	}
 
+	return job, nil
+
 }
 
-func GetJobConfig(name string) (string, *AppError) {
-	if job, err := getJob(name); err != nil {
-		LogError("[GetJobConfig] Unable to get the Job: " + name + " err=" + err.Error())
+func GetJobConfig(ctx context.Context, name string) (string, *AppError) {
+	if job, err := getJob(ctx, name); err != nil {
+		LogErrorContext(ctx, "[GetJobConfig] Unable to get the Job: "+name+" err="+err.Error())
 		return "", err
 	} else {
-		if config, err := job.GetConfig(); err != nil {
-			LogError("[GetJobConfig] Unable to get job config for job: " + name + " err=" + err.Error())
+		start := time.Now()
+		config, err := job.GetConfig()
+		observeJenkinsCall("get_config", start, err)
+		if err != nil {
+			LogErrorContext(ctx, "[GetJobConfig] Unable to get job config for job: "+name+" err="+err.Error())
 			return "", NewError("Unable to get job config", err)
 		} else {
 			return config, nil
@@ -145,40 +244,42 @@ func GetJobConfig(name string) (string, *AppError) {
 	}
 }
 
-func SaveJobConfig(name string, config string) *AppError {
-	if job, err := getJob(name); err != nil {
-		LogError("[SaveJobConfig] Unable to save job config for job: " + name + " err=" + err.Error())
+func SaveJobConfig(ctx context.Context, name string, config string) *AppError {
+	if job, err := getJob(ctx, name); err != nil {
+		LogErrorContext(ctx, "[SaveJobConfig] Unable to save job config for job: "+name+" err="+err.Error())
 		return err
 	} else {
+		start := time.Now()
 		err2 := job.UpdateConfig(config)
+		observeJenkinsCall("update_config", start, err2)
 		if err2 != nil {
-			LogError("[SaveJobConfig] Unable to update job config for job: " + name + " err=" + err.Error())
-			return NewError("Unable to update job config", err)
+			LogErrorContext(ctx, "[SaveJobConfig] Unable to update job config for job: "+name+" err="+err2.Error())
+			return NewError("Unable to update job config", err2)
 		}
 	}
 
 	return nil
 }
 
-func SetCIServerBranch(branch string) *AppError {
-	for _, serverjob := range Cfg.CIServerJobs {
-		LogInfo("[SetCIServerBranch] Setting branch " + branch + " to " + serverjob)
-		if config, err := GetJobConfig(serverjob); err != nil {
-			LogError("[SetCIServerBranch] Error getting the job config for" + serverjob + " err=" + err.Error())
+func SetCIServerBranch(ctx context.Context, branch string) *AppError {
+	for _, serverjob := range Cfg().CIServerJobs {
+		LogInfoContext(ctx, "[SetCIServerBranch] Setting branch "+branch+" to "+serverjob)
+		if config, err := GetJobConfig(ctx, serverjob); err != nil {
+			LogErrorContext(ctx, "[SetCIServerBranch] Error getting the job config for"+serverjob+" err="+err.Error())
 			return err
 		} else {
 			config = strings.Replace(config, "version='1.1'", "version='1.0'", 1)
 			config = strings.Replace(config, "version=\"1.1\"", "version=\"1.0\"", 1)
 			jConfig := etree.NewDocument()
 			if err := jConfig.ReadFromString(config); err != nil {
-				LogError("[SetCIServerBranch] Unable to read job configuration for " + serverjob + " err=" + err.Error())
+				LogErrorContext(ctx, "[SetCIServerBranch] Unable to read job configuration for "+serverjob+" err="+err.Error())
 				return NewError("Unable to read job configuration for "+serverjob, err)
 			}
 
 			// Change branch to build from
 			element := jConfig.Root().FindElement("./properties/hudson.model.ParametersDefinitionProperty/parameterDefinitions/hudson.model.StringParameterDefinition/defaultValue")
 			if element == nil {
-				LogError("[SetCIServerBranch] Unable to correct default branch element for " + serverjob)
+				LogErrorContext(ctx, "[SetCIServerBranch] Unable to correct default branch element for "+serverjob)
 				return NewError("Unable to correct default branch element for "+serverjob, nil)
 			}
 			element.SetText(branch)
@@ -199,13 +300,13 @@ func SetCIServerBranch(branch string) *AppError {
 
 			jConfigStringOut, err := jConfig.WriteToString()
 			if err != nil {
-				LogError("[SetCIServerBranch] Unable to write out final job config for " + serverjob + " err=" + err.Error())
+				LogErrorContext(ctx, "[SetCIServerBranch] Unable to write out final job config for "+serverjob+" err="+err.Error())
 				return NewError("Unable to write out final job config for "+serverjob, err)
 			}
 
 			jConfigStringOut = strings.Replace(jConfigStringOut, "version=\"1.0\"", "version=\"1.1\"", 1)
-			if err := SaveJobConfig(serverjob, jConfigStringOut); err != nil {
-				LogError("[SetCIServerBranch] Unable to save job for " + serverjob + " err=" + err.Error())
+			if err := SaveJobConfig(ctx, serverjob, jConfigStringOut); err != nil {
+				LogErrorContext(ctx, "[SetCIServerBranch] Unable to save job for "+serverjob+" err="+err.Error())
 				return NewError("Unable to save job for "+serverjob, err)
 			}
 		}
@@ -214,82 +315,170 @@ func SetCIServerBranch(branch string) *AppError {
 	return nil
 }
 
-func RunJob(name string) *AppError {
-	LogInfo("Running Job: " + name)
-	return RunJobParameters(name, nil)
+func RunJob(ctx context.Context, name string) *AppError {
+	LogInfoContext(ctx, "Running Job: "+name)
+	return RunJobParameters(ctx, name, nil)
 }
 
-func RunJobWaitForResult(name string, parameters map[string]string) (string, *AppError) {
-	job, err := getJob(name)
+func RunJobWaitForResult(ctx context.Context, name string, parameters map[string]string) (string, *AppError) {
+	return RunJobWaitForBuild(ctx, name, parameters, nil)
+}
+
+// RunJobWaitForBuild runs the job and waits for its result. The build is polled, and when the Jenkins webhook
+// is configured the build notifications are used too, onEvent is called for each of them.
+func RunJobWaitForBuild(ctx context.Context, name string, parameters map[string]string, onEvent func(*JenkinsNotification)) (string, *AppError) {
+	_, result, err := runJobWaitForBuild(ctx, name, parameters, onEvent)
+	return result, err
+}
+
+// runJobWaitForBuild is RunJobWaitForBuild that also returns the number of the build.
+func runJobWaitForBuild(ctx context.Context, name string, parameters map[string]string, onEvent func(*JenkinsNotification)) (int64, string, *AppError) {
+	job, err := getJob(ctx, name)
 	if err != nil {
-		LogError("[RunJobWaitForResult] Did not find Job: " + name + " err=" + err.Error())
-		return "", err
+		LogErrorContext(ctx, "[RunJobWaitForResult] Did not find Job: "+name+" err="+err.Error())
+		return 0, "", err
 	}
 
 	newBuildNumber := job.Raw.NextBuildNumber
 
+	fields := LogFieldsFrom(ctx)
+	op := StartOperation("Job "+name, fields["user_id"], fields["request_id"], parameters)
+	defer op.Finish()
+	op.SetState("Waiting for build #" + strconv.FormatInt(newBuildNumber, 10))
+
+	var tracked *trackedBuild
+	if Cfg().JenkinsWebhookSecret != "" {
+		tracked = trackBuild(name, newBuildNumber, onEvent)
+		defer untrackBuild(tracked)
+	}
+
+	invokedAt := time.Now()
 	_, err2 := job.InvokeSimple(parameters)
+	observeJenkinsCall("invoke", invokedAt, err2)
 	if err2 != nil {
-		LogError("[RunJobWaitForResult] Unable to envoke job " + " err=" + err.Error())
-		return "", NewError("Unable to envoke job.", err)
+		LogErrorContext(ctx, "[RunJobWaitForResult] Unable to envoke job "+" err="+err2.Error())
+		return 0, "", NewError("Unable to envoke job.", err2)
+	}
+
+	// The webhook usually reports the result first. Polling runs at the same time for the jobs without the
+	// Notification plugin and the notifications that never arrive.
+	stopPolling := make(chan struct{})
+	defer close(stopPolling)
+	polled := make(chan polledBuild, 1)
+	go func() {
+		result, err := pollBuildResult(ctx, job, name, newBuildNumber, stopPolling)
+		polled <- polledBuild{result, err}
+	}()
+
+	var notified <-chan string
+	if tracked != nil {
+		notified = tracked.result
+	}
+
+	select {
+	case result := <-notified:
+		jenkinsJobDuration.Observe(time.Since(invokedAt).Seconds(), name, result)
+		return newBuildNumber, result, nil
+	case build := <-polled:
+		if build.err != nil {
+			return newBuildNumber, "", build.err
+		}
+		jenkinsJobDuration.Observe(time.Since(invokedAt).Seconds(), name, build.result)
+		return newBuildNumber, build.result, nil
+	}
+}
+
+type polledBuild struct {
+	result string
+	err    *AppError
+}
+
+// pollBuildResult polls the build until it is finished and returns its result. It returns an empty result
+// as soon as stop is closed.
+func pollBuildResult(ctx context.Context, job *gojenkins.Job, name string, number int64, stop <-chan struct{}) (string, *AppError) {
+	sleep := func(d time.Duration) bool {
+		select {
+		case <-stop:
+			return false
+		case <-time.After(d):
+			return true
+		}
 	}
 
-	var err3 error
-	var status int
-	tries := 1
 	build := gojenkins.Build{
 		Jenkins: job.Jenkins,
 		Job:     job,
 		Raw:     new(gojenkins.BuildResponse),
 		Depth:   1,
-		Base:    "/job/" + name + "/" + strconv.FormatInt(newBuildNumber, 10),
+		Base:    "/job/" + name + "/" + strconv.FormatInt(number, 10),
 	}
-	status, err3 = build.Poll()
 
-	for ; err3 != nil || status != 200; tries += 1 {
-		status, err3 = build.Poll()
+	status, err := build.Poll()
+	for tries := 1; err != nil || status != 200; tries += 1 {
 		if tries >= 5 {
-			LogError("[RunJobWaitForResult] Unable to get build for pre-checks job: " + strconv.Itoa(int(newBuildNumber)) + " err=" + err3.Error())
-			return "", NewError("Unable to get build for pre-checks job: "+strconv.Itoa(int(newBuildNumber)), err3)
+			LogErrorContext(ctx, "[pollBuildResult] Unable to get build "+strconv.FormatInt(number, 10)+" of job: "+name+" err="+fmt.Sprint(err))
+			return "", NewError("Unable to get build "+strconv.FormatInt(number, 10)+" of job "+name, err)
+		}
+		if !sleep(time.Second * time.Duration(tries)) {
+			return "", nil
 		}
-		time.Sleep(time.Second * time.Duration(tries))
+		status, err = build.Poll()
 	}
 
 	// Wait for the build to finish
-	time.Sleep(time.Second * 5)
+	if !sleep(time.Second * 5) {
+		return "", nil
+	}
 	build.Poll()
 	for build.IsRunning() {
-		LogInfo("[RunJobWaitForResult] Waiting for job: " + name + " to complete")
-		time.Sleep(time.Second * 30)
+		LogInfoContext(ctx, "[pollBuildResult] Waiting for job: "+name+" to complete")
+		if !sleep(time.Second * 30) {
+			return "", nil
+		}
 		build.Poll()
 	}
 
 	return build.GetResult(), nil
 }
 
-func RunJobParameters(name string, parameters map[string]string) *AppError {
-	if job, err := getJob(name); err != nil {
-		return err
-	} else {
-		_, err2 := job.InvokeSimple(parameters)
-		if err2 != nil {
-			LogError("[RunJobParameters] Unable to envoke job. err=" + err.Error())
-			return NewError("Unable to envoke job.", err)
-		}
+func RunJobParameters(ctx context.Context, name string, parameters map[string]string) *AppError {
+	_, err := runJobParameters(ctx, name, parameters)
+	return err
+}
+
+// jenkinsBuildURL returns the link to a build of a job.
+func jenkinsBuildURL(name string, number int64) string {
+	return strings.TrimSuffix(Cfg().JenkinsURL, "/") + "/job/" + name + "/" + strconv.FormatInt(number, 10) + "/"
+}
+
+// runJobParameters is RunJobParameters that also returns the number of the build that was queued.
+func runJobParameters(ctx context.Context, name string, parameters map[string]string) (int64, *AppError) {
+	job, err := getJob(ctx, name)
+	if err != nil {
+		return 0, err
 	}
 
-	return nil
+	newBuildNumber := job.Raw.NextBuildNumber
+	start := time.Now()
+	_, err2 := job.InvokeSimple(parameters)
+	observeJenkinsCall("invoke", start, err2)
+	if err2 != nil {
+		LogErrorContext(ctx, "[RunJobParameters] Unable to envoke job. err="+err2.Error())
+		return 0, NewError("Unable to envoke job.", err2)
+	}
+
+	return newBuildNumber, nil
 }
 
-func SetPreReleaseTarget(target string) *AppError {
-	if config, err := GetJobConfig(Cfg.PreReleaseJob); err != nil {
+func SetPreReleaseTarget(ctx context.Context, target string) *AppError {
+	if config, err := GetJobConfig(ctx, Cfg().PreReleaseJob); err != nil {
 		return err
 	} else {
 		config = strings.Replace(config, "version='1.1'", "version='1.0'", 1)
 		config = strings.Replace(config, "version=\"1.1\"", "version=\"1.0\"", 1)
 		jConfig := etree.NewDocument()
 		if err := jConfig.ReadFromString(config); err != nil {
-			LogError("[SetPreReleaseTarget] Unable to read job configuration for pre-release. err=", err.Error())
+			LogErrorContext(ctx, "[SetPreReleaseTarget] Unable to read job configuration for pre-release. err=", err.Error())
 			return NewError("Unable to read job configuration for pre-release", err)
 		}
 
@@ -302,13 +491,13 @@ func SetPreReleaseTarget(target string) *AppError {
 
 		jConfigStringOut, err := jConfig.WriteToString()
 		if err != nil {
-			LogError("[SetPreReleaseTarget] Unable to write out final job config for pre-release job. err=" + err.Error())
+			LogErrorContext(ctx, "[SetPreReleaseTarget] Unable to write out final job config for pre-release job. err="+err.Error())
 			return NewError("Unable to write out final job config for pre-release job", err)
 		}
 
 		jConfigStringOut = strings.Replace(jConfigStringOut, "version=\"1.0\"", "version=\"1.1\"", 1)
-		if err := SaveJobConfig(Cfg.PreReleaseJob, jConfigStringOut); err != nil {
-			LogError("[SetPreReleaseTarget] Unable to save job for pre-release. err=" + err.Error())
+		if err := SaveJobConfig(ctx, Cfg().PreReleaseJob, jConfigStringOut); err != nil {
+			LogErrorContext(ctx, "[SetPreReleaseTarget] Unable to save job for pre-release. err="+err.Error())
 			return NewError("Unable to save job for pre-release", err)
 		}
 	}
@@ -316,8 +505,8 @@ func SetPreReleaseTarget(target string) *AppError {
 	return nil
 }
 
-func LoadtestKube(buildTag string, length int, delay int) *AppError {
-	RunJobParameters(Cfg.KubeDeployJob, map[string]string{
+func LoadtestKube(ctx context.Context, buildTag string, length int, delay int) *AppError {
+	RunJobParameters(ctx, Cfg().KubeDeployJob, map[string]string{
 		"BUILD_TAG":           buildTag,
 		"KUBE_BRANCH":         "master",
 		"KUBE_CONFIG_FILE":    "values_loadtest.yaml",
@@ -327,16 +516,18 @@ func LoadtestKube(buildTag string, length int, delay int) *AppError {
 	return nil
 }
 
-func IsCutReleaseRunning(name string) (bool, *AppError) {
-	job, err := getJob(name)
+func IsCutReleaseRunning(ctx context.Context, name string) (bool, *AppError) {
+	job, err := getJob(ctx, name)
 	if err != nil {
-		LogError("[IsCutReleaseRunning] Did not find Job: " + name + " err=" + err.Error())
+		LogErrorContext(ctx, "[IsCutReleaseRunning] Did not find Job: "+name+" err="+err.Error())
 		return false, err
 	}
 
+	start := time.Now()
 	build, err1 := job.GetLastBuild()
+	observeJenkinsCall("get_last_build", start, err1)
 	if err1 != nil {
-		LogError("[IsCutReleaseRunning] Error getting the last build for: " + name + " err=" + err1.Error())
+		LogErrorContext(ctx, "[IsCutReleaseRunning] Error getting the last build for: "+name+" err="+err1.Error())
 		return false, NewError("Unable to get last build", err1)
 	}
 
@@ -347,17 +538,19 @@ func IsCutReleaseRunning(name string) (bool, *AppError) {
 	return false, nil
 }
 
-func GetLatestResult(name string) (*JenkinsStatus, *AppError) {
+func GetLatestResult(ctx context.Context, name string) (*JenkinsStatus, *AppError) {
 	buildStatus := &JenkinsStatus{}
-	job, err := getJob(name)
+	job, err := getJob(ctx, name)
 	if err != nil {
-		LogError("[GetLatestResult] Did not find Job: " + name + " err=" + err.Error())
+		LogErrorContext(ctx, "[GetLatestResult] Did not find Job: "+name+" err="+err.Error())
 		return nil, err
 	}
 
+	start := time.Now()
 	build, err1 := job.GetLastBuild()
+	observeJenkinsCall("get_last_build", start, err1)
 	if err1 != nil {
-		LogError("[GetLatestResult] Error getting the last build for: " + name + " err=" + err1.Error())
+		LogErrorContext(ctx, "[GetLatestResult] Error getting the last build for: "+name+" err="+err1.Error())
 		return nil, NewError("Unable to get last build", err1)
 	}
 
@@ -378,28 +571,63 @@ func GetLatestResult(name string) (*JenkinsStatus, *AppError) {
 	return buildStatus, nil
 }
 
-func GetJenkinsArtifacts(jobname string) ([]gojenkins.Artifact, *AppError) {
-	job, err := getJob(jobname)
+// GetTranslationServerBranches runs the CheckTranslationServerJob and returns the branches the translation
+// server is locked to, e.g. PLT_BRANCH, WEB_BRANCH and RN_BRANCH.
+func GetTranslationServerBranches(ctx context.Context) (map[string]string, *AppError) {
+	number, result, err := runJobWaitForBuild(ctx, Cfg().CheckTranslationServerJob, map[string]string{}, nil)
 	if err != nil {
-		LogError("[GetJenkinsArtifact] Did not find Job: " + jobname + " err=" + err.Error())
+		LogErrorContext(ctx, "[GetTranslationServerBranches] Translation job failed. err= "+err.Error())
 		return nil, err
 	}
+	if result != gojenkins.STATUS_SUCCESS {
+		LogErrorContext(ctx, "[GetTranslationServerBranches] Translation job failed. Jenkins result= "+result)
+		return nil, NewError("Jenkins Status: "+result, nil)
+	}
 
-	build, err1 := job.GetLastBuild()
+	// The artifact of the build that was just run, another build may have finished since.
+	artifacts, err := GetJenkinsArtifacts(ctx, Cfg().CheckTranslationServerJob, number)
+	if err != nil {
+		return nil, err
+	}
+	dat, err1 := artifacts[0].GetData()
 	if err1 != nil {
-		LogError("[GetJenkinsArtifact] Error getting the last build for: " + jobname + " err=" + err1.Error())
-		return nil, NewError("Unable to get last build", err1)
+		LogErrorContext(ctx, "[GetTranslationServerBranches] Unable to read the artifact "+artifacts[0].FileName+" err="+err1.Error())
+		return nil, NewError("Unable to read the translation job artifact", err1)
+	}
+
+	// The artifact has lines like PLT_BRANCH="release-5.1"
+	branches := map[string]string{}
+	for _, line := range strings.Split(string(dat), "\n") {
+		split := strings.SplitN(strings.TrimSpace(line), "=", 2)
+		if len(split) == 2 {
+			branches[split[0]] = strings.Trim(split[1], "\"")
+		}
+	}
+
+	return branches, nil
+}
+
+// GetJenkinsArtifacts returns the artifacts of a build of the job.
+func GetJenkinsArtifacts(ctx context.Context, jobname string, number int64) ([]gojenkins.Artifact, *AppError) {
+	job, err := getJob(ctx, jobname)
+	if err != nil {
+		LogErrorContext(ctx, "[GetJenkinsArtifact] Did not find Job: "+jobname+" err="+err.Error())
+		return nil, err
+	}
+
+	start := time.Now()
+	build, err1 := job.GetBuild(number)
+	observeJenkinsCall("get_build", start, err1)
+	if err1 != nil {
+		LogErrorContext(ctx, "[GetJenkinsArtifact] Error getting the build "+strconv.FormatInt(number, 10)+" of: "+jobname+" err="+err1.Error())
+		return nil, NewError("Unable to get the build", err1)
 	}
 
 	artifacts := build.GetArtifacts()
 	if len(artifacts) == 0 {
-		LogError("[GetJenkinsArtifact] No artifacts returned: " + jobname)
+		LogErrorContext(ctx, "[GetJenkinsArtifact] No artifacts returned: "+jobname)
 		return nil, NewError("No artifacts returned", nil)
 	}
 
-	for _, a := range artifacts {
-		a.SaveToDir("/tmp")
-	}
-
 	return artifacts, nil
 }
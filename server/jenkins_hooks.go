@@ -0,0 +1,145 @@
+// Copyright (c) 2018-present Mattermost, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/julienschmidt/httprouter"
+
+	"github.com/mattermost/matterbuild/utils"
+)
+
+const (
+	JENKINS_PHASE_QUEUED    = "QUEUED"
+	JENKINS_PHASE_STARTED   = "STARTED"
+	JENKINS_PHASE_COMPLETED = "COMPLETED"
+	JENKINS_PHASE_FINALIZED = "FINALIZED"
+)
+
+// JenkinsNotification is the payload sent by the Jenkins Notification plugin.
+type JenkinsNotification struct {
+	Name  string                   `json:"name"`
+	Url   string                   `json:"url"`
+	Build JenkinsNotificationBuild `json:"build"`
+}
+
+type JenkinsNotificationBuild struct {
+	FullUrl    string            `json:"full_url"`
+	Number     int64             `json:"number"`
+	QueueId    int64             `json:"queue_id"`
+	Phase      string            `json:"phase"`
+	Status     string            `json:"status"`
+	Url        string            `json:"url"`
+	Parameters map[string]string `json:"parameters"`
+}
+
+// JobName returns the full name of the job, including folders, as used in the config.
+func (n *JenkinsNotification) JobName() string {
+	if n.Url == "" {
+		return n.Name
+	}
+
+	var parts []string
+	segments := strings.Split(strings.Trim(n.Url, "/"), "/")
+	for i := 0; i+1 < len(segments); i += 2 {
+		if segments[i] == "job" {
+			parts = append(parts, segments[i+1])
+		}
+	}
+	if len(parts) == 0 {
+		return n.Name
+	}
+
+	return strings.Join(parts, "/")
+}
+
+// trackedBuild is a Jenkins build started by matterbuild that is waiting for webhook events.
+type trackedBuild struct {
+	Job     string
+	Number  int64
+	onEvent func(*JenkinsNotification)
+	result  chan string
+}
+
+var trackedBuilds = map[string]*trackedBuild{}
+var trackedBuildsLock sync.Mutex
+
+func trackedBuildKey(job string, number int64) string {
+	return strings.Trim(job, "/") + "#" + strconv.FormatInt(number, 10)
+}
+
+func trackBuild(job string, number int64, onEvent func(*JenkinsNotification)) *trackedBuild {
+	build := &trackedBuild{
+		Job:     job,
+		Number:  number,
+		onEvent: onEvent,
+		result:  make(chan string, 1),
+	}
+
+	trackedBuildsLock.Lock()
+	trackedBuilds[trackedBuildKey(job, number)] = build
+	trackedBuildsLock.Unlock()
+
+	return build
+}
+
+func untrackBuild(build *trackedBuild) {
+	trackedBuildsLock.Lock()
+	delete(trackedBuilds, trackedBuildKey(build.Job, build.Number))
+	trackedBuildsLock.Unlock()
+}
+
+func getTrackedBuild(job string, number int64) *trackedBuild {
+	trackedBuildsLock.Lock()
+	defer trackedBuildsLock.Unlock()
+	return trackedBuilds[trackedBuildKey(job, number)]
+}
+
+func (b *trackedBuild) handleNotification(notification *JenkinsNotification) {
+	if b.onEvent != nil {
+		b.onEvent(notification)
+	}
+
+	if notification.Build.Phase == JENKINS_PHASE_COMPLETED || notification.Build.Phase == JENKINS_PHASE_FINALIZED {
+		select {
+		case b.result <- notification.Build.Status:
+		default:
+		}
+	}
+}
+
+func jenkinsHookHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if Cfg().JenkinsWebhookSecret == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	// The secret is only accepted in a header, query strings end up in proxy and access logs.
+	if !utils.SecureCompare(r.Header.Get("X-Matterbuild-Token"), Cfg().JenkinsWebhookSecret) {
+		LogError("[jenkinsHookHandler] Received Jenkins webhook with an invalid token")
+		http.Error(w, "Invalid token", http.StatusUnauthorized)
+		return
+	}
+
+	notification := &JenkinsNotification{}
+	if err := json.NewDecoder(r.Body).Decode(notification); err != nil {
+		LogError("[jenkinsHookHandler] Unable to decode Jenkins notification err=" + err.Error())
+		http.Error(w, "Unable to decode notification", http.StatusBadRequest)
+		return
+	}
+
+	jobName := notification.JobName()
+	LogInfo("[jenkinsHookHandler] Job: " + jobName + " Build: " + strconv.FormatInt(notification.Build.Number, 10) + " Phase: " + notification.Build.Phase + " Status: " + notification.Build.Status)
+
+	if build := getTrackedBuild(jobName, notification.Build.Number); build != nil {
+		build.handleNotification(notification)
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,196 @@
+// Copyright (c) 2018-present Mattermost, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newFakeJenkins serves the Jenkins API calls of running a job and polling its build. The build is running
+// while result is empty.
+func newFakeJenkins(job string, number int64, result string) *httptest.Server {
+	jobPath := "/job/" + job
+	buildPath := jobPath + "/" + strconv.FormatInt(number, 10)
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimSuffix(r.URL.Path, "/")
+		switch {
+		case r.Method == http.MethodPost && path == jobPath+"/build":
+			w.Header().Set("Location", "/queue/item/42/")
+			w.WriteHeader(http.StatusCreated)
+		case path == jobPath+"/api/json":
+			fmt.Fprintf(w, `{"name": %q, "nextBuildNumber": %v, "inQueue": false}`, job, number)
+		case path == buildPath+"/api/json":
+			if result == "" {
+				fmt.Fprintf(w, `{"number": %v, "building": true, "result": null}`, number)
+			} else {
+				fmt.Fprintf(w, `{"number": %v, "building": false, "result": %q}`, number, result)
+			}
+		default:
+			fmt.Fprint(w, "{}")
+		}
+	}))
+}
+
+func TestJenkinsNotificationJobName(t *testing.T) {
+	for _, tc := range []struct {
+		notification JenkinsNotification
+		expected     string
+	}{
+		{JenkinsNotification{Name: "cut", Url: "job/cut/"}, "cut"},
+		{JenkinsNotification{Name: "release", Url: "job/mattermost/job/release/"}, "mattermost/release"},
+		{JenkinsNotification{Name: "release", Url: "/job/mattermost/job/release"}, "mattermost/release"},
+		{JenkinsNotification{Name: "release", Url: "job/mattermost/job/release/12/"}, "mattermost/release"},
+		{JenkinsNotification{Name: "release"}, "release"},
+		{JenkinsNotification{Name: "release", Url: "view/all/"}, "release"},
+	} {
+		if got := tc.notification.JobName(); got != tc.expected {
+			t.Errorf("%+v: JobName() = %q, expected %q", tc.notification, got, tc.expected)
+		}
+	}
+}
+
+func postJenkinsNotification(token, body string, header bool) *httptest.ResponseRecorder {
+	target := "/hooks/jenkins"
+	if !header {
+		target += "?token=" + token
+	}
+	r := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+	if header {
+		r.Header.Set("X-Matterbuild-Token", token)
+	}
+
+	w := httptest.NewRecorder()
+	jenkinsHookHandler(w, r, nil)
+	return w
+}
+
+func jenkinsNotificationBody(job string, number int64, phase, status string) string {
+	return fmt.Sprintf(`{"name": %q, "url": "job/%v/", "build": {"number": %v, "phase": %q, "status": %q}}`, job, job, number, phase, status)
+}
+
+func TestJenkinsHookHandler(t *testing.T) {
+	defer setTestConfig(t, &MatterbuildConfig{JenkinsWebhookSecret: "secret"})()
+
+	body := jenkinsNotificationBody("cut", 12, JENKINS_PHASE_COMPLETED, "SUCCESS")
+	for _, tc := range []struct {
+		name     string
+		token    string
+		header   bool
+		body     string
+		expected int
+	}{
+		{name: "valid", token: "secret", header: true, body: body, expected: http.StatusOK},
+		{name: "unknown build", token: "secret", header: true, body: jenkinsNotificationBody("other", 1, JENKINS_PHASE_COMPLETED, "SUCCESS"), expected: http.StatusOK},
+		{name: "bad token", token: "wrong", header: true, body: body, expected: http.StatusUnauthorized},
+		{name: "missing token", header: true, body: body, expected: http.StatusUnauthorized},
+		{name: "token in the query string", token: "secret", body: body, expected: http.StatusUnauthorized},
+		{name: "bad payload", token: "secret", header: true, body: "{", expected: http.StatusBadRequest},
+	} {
+		if w := postJenkinsNotification(tc.token, tc.body, tc.header); w.Code != tc.expected {
+			t.Errorf("%v: got status %v, expected %v", tc.name, w.Code, tc.expected)
+		}
+	}
+
+	// Without a secret the endpoint is disabled.
+	currentConfig.Store(&MatterbuildConfig{})
+	if w := postJenkinsNotification("", body, true); w.Code != http.StatusNotFound {
+		t.Errorf("expected the endpoint to be disabled, got status %v", w.Code)
+	}
+}
+
+func TestJenkinsHookHandlerTrackedBuild(t *testing.T) {
+	defer setTestConfig(t, &MatterbuildConfig{JenkinsWebhookSecret: "secret"})()
+
+	var phases []string
+	build := trackBuild("folder/cut", 12, func(notification *JenkinsNotification) {
+		phases = append(phases, notification.Build.Phase)
+	})
+	defer untrackBuild(build)
+
+	started := `{"name": "cut", "url": "job/folder/job/cut/", "build": {"number": 12, "phase": "STARTED"}}`
+	completed := `{"name": "cut", "url": "job/folder/job/cut/", "build": {"number": 12, "phase": "COMPLETED", "status": "FAILURE"}}`
+	other := `{"name": "cut", "url": "job/folder/job/cut/", "build": {"number": 11, "phase": "COMPLETED", "status": "SUCCESS"}}`
+	for _, body := range []string{started, other, completed} {
+		if w := postJenkinsNotification("secret", body, true); w.Code != http.StatusOK {
+			t.Fatalf("got status %v", w.Code)
+		}
+	}
+
+	if strings.Join(phases, ",") != "STARTED,COMPLETED" {
+		t.Errorf("expected the events of build 12, got %v", phases)
+	}
+	select {
+	case result := <-build.result:
+		if result != "FAILURE" {
+			t.Errorf("expected the result FAILURE, got %v", result)
+		}
+	default:
+		t.Error("the result of the build wasn't delivered")
+	}
+}
+
+func TestRunJobWaitForBuildWebhook(t *testing.T) {
+	// Jenkins reports the build as running, only the webhook tells it completed.
+	jenkins := newFakeJenkins("cut", 7, "")
+	defer jenkins.Close()
+	defer setTestConfig(t, &MatterbuildConfig{JenkinsURL: jenkins.URL, JenkinsWebhookSecret: "secret"})()
+
+	type waitResult struct {
+		number int64
+		result string
+		err    *AppError
+	}
+	done := make(chan waitResult, 1)
+	go func() {
+		number, result, err := runJobWaitForBuild(context.Background(), "cut", nil, nil)
+		done <- waitResult{number, result, err}
+	}()
+
+	for deadline := time.Now().Add(5 * time.Second); getTrackedBuild("cut", 7) == nil; {
+		if time.Now().After(deadline) {
+			t.Fatal("the build was never tracked")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if w := postJenkinsNotification("secret", jenkinsNotificationBody("cut", 7, JENKINS_PHASE_COMPLETED, "SUCCESS"), true); w.Code != http.StatusOK {
+		t.Fatalf("got status %v", w.Code)
+	}
+
+	select {
+	case got := <-done:
+		if got.err != nil || got.number != 7 || got.result != "SUCCESS" {
+			t.Errorf("expected build 7 to succeed, got %+v", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the webhook result wasn't handed to the waiting job")
+	}
+
+	if getTrackedBuild("cut", 7) != nil {
+		t.Error("expected the build to be untracked")
+	}
+}
+
+func TestRunJobWaitForBuildPolling(t *testing.T) {
+	if testing.Short() {
+		t.Skip("polling waits for the build")
+	}
+
+	// Without the webhook, the result is polled.
+	jenkins := newFakeJenkins("cut", 7, "FAILURE")
+	defer jenkins.Close()
+	defer setTestConfig(t, &MatterbuildConfig{JenkinsURL: jenkins.URL})()
+
+	number, result, err := runJobWaitForBuild(context.Background(), "cut", nil, nil)
+	if err != nil || number != 7 || result != "FAILURE" {
+		t.Errorf("expected build 7 to fail, got %v %v (err=%v)", number, result, err)
+	}
+}
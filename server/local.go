@@ -0,0 +1,65 @@
+// Copyright (c) 2018-present Mattermost, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package server
+
+import (
+	"context"
+	"strings"
+)
+
+// ExecuteLocalCommand runs a matterbuild command from a terminal, without going through Mattermost.
+// Token and user checks are skipped since the operator already has access to the config and its credentials,
+// the operator name is only logged for auditing. Freezes still apply unless the command is run with --force.
+func ExecuteLocalCommand(operator string, channelId string, args []string) (*MMSlashResponse, *AppError) {
+	command := &MMSlashCommand{
+		ChannelId: channelId,
+		Command:   "exec",
+		Text:      strings.Join(args, " "),
+		UserId:    operator,
+		Username:  operator,
+	}
+
+	command = command.WithContext(commandContext(context.Background(), command))
+
+	// The operator has the release admin credentials at hand, so they can force a command during a freeze.
+	cmd, err := resolveCommand(command)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkFreezeOverride(command, cmd, true); err != nil {
+		return nil, err
+	}
+
+	buffer := newBufferedResponseWriter()
+	LogInfoContext(command.Context(), "[ExecuteLocalCommand] Operator "+operator+" running: "+command.Text)
+	executeCommand(buffer, command)
+
+	response := buffer.SlashResponse()
+	if response == nil {
+		return nil, NewError("The command didn't return a response", nil)
+	}
+
+	return response, nil
+}
+
+// FormatResponseText renders a slash response as plain text for a terminal.
+func FormatResponseText(response *MMSlashResponse) string {
+	var parts []string
+	if response.Text != "" {
+		parts = append(parts, response.Text)
+	}
+
+	if response.Attachments != nil {
+		for _, attachment := range *response.Attachments {
+			if attachment.Title != "" {
+				parts = append(parts, "## "+attachment.Title)
+			}
+			if attachment.Text != "" {
+				parts = append(parts, attachment.Text)
+			}
+		}
+	}
+
+	return strings.Join(parts, "\n")
+}
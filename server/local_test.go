@@ -0,0 +1,40 @@
+// Copyright (c) 2018-present Mattermost, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package server
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExecuteLocalCommandDuringAFreeze(t *testing.T) {
+	defer setTestConfig(t, &MatterbuildConfig{ReleaseAdmins: []string{"admin"}})()
+
+	if _, err := setAdHocFreeze(&AdHocFreeze{Reason: "Testing", Username: "admin", StartedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ExecuteLocalCommand("operator", "", []string{"cut", "--confirm"}); err == nil || !strings.Contains(err.Error(), "Releases are frozen") {
+		t.Errorf("expected the freeze to block the cut, got %v", err)
+	}
+
+	// The operator isn't a release admin in Mattermost but can still force it.
+	response, err := ExecuteLocalCommand("operator", "", []string{"cut", "--force", "--confirm"})
+	if err != nil {
+		t.Fatalf("expected the forced cut to run, got %v", err)
+	}
+	if text := FormatResponseText(response); !strings.Contains(text, "There is no version waiting for your confirmation") {
+		t.Errorf("expected the response of the cut, got %q", text)
+	}
+
+	// Commands that aren't frozen run as usual.
+	if _, err := ExecuteLocalCommand("operator", "", []string{"freeze", "list"}); err != nil {
+		t.Errorf("expected the command to run, got %v", err)
+	}
+
+	if _, err := ExecuteLocalCommand("operator", "", []string{"nosuchcommand"}); err == nil {
+		t.Error("expected an unknown command to be rejected")
+	}
+}
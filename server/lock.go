@@ -0,0 +1,150 @@
+// Copyright (c) 2018-present Mattermost, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const RELEASE_LOCK_FILE = "release_lock.json"
+
+// ReleaseLock is held while a command changes the state of a release (cut, setci, setprerelease, merge),
+// so two of them can't run at the same time. It is persisted so a lock held when matterbuild stopped
+// has to be released by an admin once they checked the state of the release.
+type ReleaseLock struct {
+	Id         string
+	UserId     string
+	Username   string
+	Command    string
+	AcquiredAt time.Time
+}
+
+// updateReleaseLock lets update change the lock currently held and saves it if update reports a change.
+func updateReleaseLock(update func(held *ReleaseLock) (*ReleaseLock, bool, *AppError)) *AppError {
+	var held *ReleaseLock
+	return updateJSON(RELEASE_LOCK_FILE, &held, func() (bool, *AppError) {
+		var changed bool
+		var err *AppError
+		held, changed, err = update(held)
+		return changed, err
+	})
+}
+
+func describeReleaseLock(lock *ReleaseLock) string {
+	return fmt.Sprintf("@%v has held it for `%v` since %v", lock.Username, lock.Command, lock.AcquiredAt.Format("2006-01-02 15:04:05 MST"))
+}
+
+// AcquireReleaseLock takes the release lock for the command, or fails if it is already held.
+func AcquireReleaseLock(slashCommand *MMSlashCommand) (*ReleaseLock, *AppError) {
+	lock := &ReleaseLock{
+		Id:         NewRequestId(),
+		UserId:     slashCommand.UserId,
+		Username:   slashCommand.Username,
+		Command:    slashCommand.Text,
+		AcquiredAt: time.Now(),
+	}
+
+	err := updateReleaseLock(func(held *ReleaseLock) (*ReleaseLock, bool, *AppError) {
+		if held != nil {
+			return held, false, NewError("Another release command is running, "+describeReleaseLock(held)+". Check `/matterbuild lock status`.", nil)
+		}
+		return lock, true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	LogInfoContext(slashCommand.Context(), "[AcquireReleaseLock] Release lock acquired by "+lock.Username+" for "+lock.Command)
+	return lock, nil
+}
+
+// Release gives the lock back. It does nothing if the lock was already released by an admin.
+func (l *ReleaseLock) Release() {
+	released := false
+	err := updateReleaseLock(func(held *ReleaseLock) (*ReleaseLock, bool, *AppError) {
+		if held == nil || held.Id != l.Id {
+			return held, false, nil
+		}
+		released = true
+		return nil, true, nil
+	})
+	if err != nil {
+		LogError("[ReleaseLock.Release] Unable to release the lock held by " + l.Username + " for " + l.Command + " err=" + err.Error())
+		return
+	}
+
+	if released {
+		LogInfo("[ReleaseLock.Release] Release lock released by " + l.Username + " for " + l.Command)
+	}
+}
+
+// CurrentReleaseLock returns the lock currently held, or nil.
+func CurrentReleaseLock() *ReleaseLock {
+	var held *ReleaseLock
+	if _, err := loadJSON(RELEASE_LOCK_FILE, &held); err != nil {
+		LogError("[CurrentReleaseLock] Unable to load the release lock err=" + err.Error())
+		return nil
+	}
+	return held
+}
+
+// forceReleaseLock releases the lock whoever holds it.
+func forceReleaseLock() *ReleaseLock {
+	var released *ReleaseLock
+	err := updateReleaseLock(func(held *ReleaseLock) (*ReleaseLock, bool, *AppError) {
+		released = held
+		return nil, held != nil, nil
+	})
+	if err != nil {
+		LogError("[forceReleaseLock] Unable to release the lock err=" + err.Error())
+		return nil
+	}
+	return released
+}
+
+// isReleaseAdmin reports if the user is one of the ReleaseAdmins, or of the ReleaseUsers when there are no admins.
+func isReleaseAdmin(userId string) bool {
+	admins := Cfg().ReleaseAdmins
+	if len(admins) == 0 {
+		admins = Cfg().ReleaseUsers
+	}
+
+	for _, admin := range admins {
+		if admin == userId {
+			return true
+		}
+	}
+	return false
+}
+
+func lockStatusCmdF(args []string, w http.ResponseWriter, slashCommand *MMSlashCommand) error {
+	lock := CurrentReleaseLock()
+	if lock == nil {
+		WriteEnrichedResponse(w, "Release Lock", "The release lock is free.", "#0060aa", EPHEMERAL)
+		return nil
+	}
+
+	msg := "The release lock is held, " + describeReleaseLock(lock) + "."
+	WriteEnrichedResponse(w, "Release Lock", msg, "#0060aa", EPHEMERAL)
+	return nil
+}
+
+func lockReleaseCmdF(args []string, w http.ResponseWriter, slashCommand *MMSlashCommand) error {
+	if !isReleaseAdmin(slashCommand.UserId) {
+		return NewError("Only release admins can release the lock.", nil)
+	}
+
+	lock := forceReleaseLock()
+	if lock == nil {
+		WriteEnrichedResponse(w, "Release Lock", "The release lock is already free.", "#0060aa", EPHEMERAL)
+		return nil
+	}
+
+	LogInfoContext(slashCommand.Context(), "[lockReleaseCmdF] Release lock held by "+lock.Username+" for "+lock.Command+" released by "+slashCommand.Username)
+	msg := fmt.Sprintf("Released the lock @%v held for `%v` since %v.", lock.Username, lock.Command, lock.AcquiredAt.Format("2006-01-02 15:04:05 MST"))
+	WriteEnrichedResponse(w, "Release Lock", msg, "#86c323", IN_CHANNEL)
+	return nil
+}
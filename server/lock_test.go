@@ -0,0 +1,53 @@
+// Copyright (c) 2018-present Mattermost, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReleaseLock(t *testing.T) {
+	defer setTestConfig(t, &MatterbuildConfig{})()
+
+	command := &MMSlashCommand{UserId: "user", Username: "user", Text: "cut 5.1.0"}
+
+	lock, err := AcquireReleaseLock(command)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := AcquireReleaseLock(command); err == nil {
+		t.Fatal("expected the lock to be held")
+	}
+	if held := CurrentReleaseLock(); held == nil || held.Id != lock.Id {
+		t.Fatalf("expected the current lock to be %v, got %+v", lock.Id, held)
+	}
+
+	lock.Release()
+	if held := CurrentReleaseLock(); held != nil {
+		t.Fatalf("expected the lock to be released, got %+v", held)
+	}
+
+	// A lock taken by another matterbuild process, e.g. the local exec mode, is seen without a restart.
+	other := &ReleaseLock{Id: "other", Username: "operator", Command: "setci release-5.1", AcquiredAt: time.Now()}
+	if err := saveJSON(RELEASE_LOCK_FILE, other); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := AcquireReleaseLock(command); err == nil {
+		t.Fatal("expected the lock of the other process to be held")
+	}
+
+	// Releasing a lock that was already taken over does nothing.
+	lock.Release()
+	if held := CurrentReleaseLock(); held == nil || held.Id != "other" {
+		t.Fatalf("expected the other lock to be kept, got %+v", held)
+	}
+
+	if released := forceReleaseLock(); released == nil || released.Id != "other" {
+		t.Fatalf("expected the other lock to be force released, got %+v", released)
+	}
+	if _, err := AcquireReleaseLock(command); err != nil {
+		t.Fatalf("expected the lock to be free, got %v", err)
+	}
+}
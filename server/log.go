@@ -4,38 +4,314 @@
 package server
 
 import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
-	"log"
+	"io"
 	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	LOG_LEVEL_DEBUG = "debug"
+	LOG_LEVEL_INFO  = "info"
+	LOG_LEVEL_ERROR = "error"
+	LOG_LEVEL_CRIT  = "crit"
+
+	LOG_FORMAT_LOGFMT = "logfmt"
+	LOG_FORMAT_JSON   = "json"
 
-	l4g "github.com/alecthomas/log4go"
+	LOG_OUTPUT_STDOUT = "stdout"
+	LOG_OUTPUT_STDERR = "stderr"
+
+	DEFAULT_LOG_OUTPUT = "matterbuild.log"
 )
 
+var logLevels = map[string]int{
+	LOG_LEVEL_DEBUG: 0,
+	LOG_LEVEL_INFO:  1,
+	LOG_LEVEL_ERROR: 2,
+	LOG_LEVEL_CRIT:  3,
+}
+
+// LogFields are attached to every log line emitted with a context that has them, see WithLogFields.
+type LogFields map[string]string
+
+type logger struct {
+	sync.Mutex
+	level  int
+	format string
+	output string
+	out    io.WriteCloser
+}
+
+// Until the config is loaded, log everything from info up to stderr.
+var stdLogger = &logger{
+	level:  logLevels[LOG_LEVEL_INFO],
+	format: LOG_FORMAT_LOGFMT,
+	output: LOG_OUTPUT_STDERR,
+	out:    nopCloser{os.Stderr},
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
+
+// ConfigureLogging applies the logging settings of the config. It is called every time the config is loaded.
+func ConfigureLogging(c *MatterbuildConfig) *AppError {
+	level := c.LogLevel
+	if level == "" {
+		level = LOG_LEVEL_INFO
+	}
+	format := c.LogFormat
+	if format == "" {
+		format = LOG_FORMAT_LOGFMT
+	}
+	output := c.LogOutput
+	if output == "" {
+		output = DEFAULT_LOG_OUTPUT
+	}
+
+	stdLogger.Lock()
+	defer stdLogger.Unlock()
+
+	stdLogger.level = logLevels[level]
+	stdLogger.format = format
+
+	if output == stdLogger.output {
+		if file, ok := stdLogger.out.(*rotatingFile); ok {
+			file.setLimits(c.LogMaxSizeMB, c.LogMaxBackups)
+		}
+		return nil
+	}
+
+	var out io.WriteCloser
+	switch output {
+	case LOG_OUTPUT_STDOUT:
+		out = nopCloser{os.Stdout}
+	case LOG_OUTPUT_STDERR:
+		out = nopCloser{os.Stderr}
+	default:
+		file, err := openRotatingFile(output, c.LogMaxSizeMB, c.LogMaxBackups)
+		if err != nil {
+			return NewError("Unable to open the log file "+output, err)
+		}
+		out = file
+	}
+
+	stdLogger.out.Close()
+	stdLogger.out = out
+	stdLogger.output = output
+	return nil
+}
+
+func (l *logger) log(level string, msg string, fields LogFields) {
+	l.Lock()
+	defer l.Unlock()
+
+	if logLevels[level] < l.level {
+		return
+	}
+
+	line := &bytes.Buffer{}
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+	msg = Redact(msg)
+
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	if l.format == LOG_FORMAT_JSON {
+		entry := map[string]string{"time": now, "level": level, "msg": msg}
+		for _, key := range keys {
+			entry[key] = Redact(fields[key])
+		}
+		b, _ := json.Marshal(entry)
+		line.Write(b)
+	} else {
+		fmt.Fprintf(line, "time=%v level=%v msg=%v", now, level, logfmtValue(msg))
+		for _, key := range keys {
+			fmt.Fprintf(line, " %v=%v", key, logfmtValue(Redact(fields[key])))
+		}
+	}
+	line.WriteByte('\n')
+
+	l.out.Write(line.Bytes())
+}
+
+func logfmtValue(value string) string {
+	if value != "" && !strings.ContainsAny(value, " =\"\t\r\n") {
+		return value
+	}
+	return strconv.Quote(value)
+}
+
+func LogDebug(msg string, args ...interface{}) {
+	Log(LOG_LEVEL_DEBUG, msg, args...)
+}
+
 func LogInfo(msg string, args ...interface{}) {
-	l4g.Info(msg, args...)
-	Log("INFO", msg, args...)
+	Log(LOG_LEVEL_INFO, msg, args...)
 }
 
 func LogError(msg string, args ...interface{}) {
-	l4g.Error(msg, args...)
-	Log("ERROR", msg, args...)
+	Log(LOG_LEVEL_ERROR, msg, args...)
 }
 
 func LogCritical(msg string, args ...interface{}) {
-	l4g.Critical(msg, args...)
-	Log("CRIT", msg, args...)
+	Log(LOG_LEVEL_CRIT, msg, args...)
 	panic(fmt.Sprintf(msg, args...))
 }
 
 func Log(level string, msg string, args ...interface{}) {
-	log.Printf("%v %v\n", level, fmt.Sprintf(msg, args...))
-	f, err := os.OpenFile("matterbuild.log", os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
+	LogContext(context.Background(), level, msg, args...)
+}
+
+func LogDebugContext(ctx context.Context, msg string, args ...interface{}) {
+	LogContext(ctx, LOG_LEVEL_DEBUG, msg, args...)
+}
+
+func LogInfoContext(ctx context.Context, msg string, args ...interface{}) {
+	LogContext(ctx, LOG_LEVEL_INFO, msg, args...)
+}
+
+func LogErrorContext(ctx context.Context, msg string, args ...interface{}) {
+	LogContext(ctx, LOG_LEVEL_ERROR, msg, args...)
+}
+
+// LogContext logs the message with the log fields of ctx, see WithLogFields.
+func LogContext(ctx context.Context, level string, msg string, args ...interface{}) {
+	if len(args) > 0 {
+		msg = fmt.Sprintf(msg, args...)
+	}
+	stdLogger.log(level, msg, LogFieldsFrom(ctx))
+}
+
+type logFieldsKey struct{}
+
+// WithLogFields returns a copy of ctx with the fields added to the ones it already has. The request and user
+// ids reach the Jenkins and GitHub code through the context passed to them.
+func WithLogFields(ctx context.Context, fields LogFields) context.Context {
+	merged := LogFields{}
+	for key, value := range LogFieldsFrom(ctx) {
+		merged[key] = value
+	}
+	for key, value := range fields {
+		merged[key] = value
+	}
+	return context.WithValue(ctx, logFieldsKey{}, merged)
+}
+
+// LogFieldsFrom returns the log fields of ctx, nil if it has none.
+func LogFieldsFrom(ctx context.Context) LogFields {
+	if ctx == nil {
+		return nil
+	}
+	fields, _ := ctx.Value(logFieldsKey{}).(LogFields)
+	return fields
+}
+
+// NewRequestId returns a random id to correlate the log lines of a request.
+func NewRequestId() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return hex.EncodeToString(b)
+}
+
+// commandContext returns a context with the log fields of parent, the id of the user running the command and
+// a request id if parent doesn't have one yet. It isn't canceled with parent, since the commands keep running
+// in the background after the response is written.
+func commandContext(parent context.Context, command *MMSlashCommand) context.Context {
+	fields := LogFields{"user_id": command.UserId}
+	if _, ok := LogFieldsFrom(parent)["request_id"]; !ok {
+		fields["request_id"] = NewRequestId()
+	}
+	return WithLogFields(WithLogFields(context.Background(), LogFieldsFrom(parent)), fields)
+}
+
+// rotatingFile is a log file that is rotated to name.1, name.2... when it grows over the max size.
+type rotatingFile struct {
+	name       string
+	file       *os.File
+	size       int64
+	maxSize    int64
+	maxBackups int
+}
+
+func openRotatingFile(name string, maxSizeMB int, maxBackups int) (*rotatingFile, error) {
+	f := &rotatingFile{name: name}
+	f.setLimits(maxSizeMB, maxBackups)
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *rotatingFile) setLimits(maxSizeMB int, maxBackups int) {
+	f.maxSize = int64(maxSizeMB) * 1024 * 1024
+	f.maxBackups = maxBackups
+}
+
+func (f *rotatingFile) open() error {
+	file, err := os.OpenFile(f.name, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0640)
 	if err != nil {
-		log.Printf("Failed to write to file")
-		return
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
 	}
-	defer f.Close()
 
-	log.SetOutput(f)
-	log.Printf("%v %v\n", level, fmt.Sprintf(msg, args...))
+	f.file = file
+	f.size = info.Size()
+	return nil
+}
+
+func (f *rotatingFile) rotate() error {
+	f.file.Close()
+
+	if f.maxBackups > 0 {
+		for i := f.maxBackups - 1; i > 0; i-- {
+			os.Rename(f.name+"."+strconv.Itoa(i), f.name+"."+strconv.Itoa(i+1))
+		}
+		os.Rename(f.name, f.name+".1")
+	} else {
+		os.Remove(f.name)
+	}
+
+	return f.open()
+}
+
+func (f *rotatingFile) Write(b []byte) (int, error) {
+	if f.maxSize > 0 && f.size+int64(len(b)) > f.maxSize && f.size > 0 {
+		if err := f.rotate(); err != nil {
+			fmt.Fprintln(os.Stderr, "Unable to rotate the log file "+f.name+": "+err.Error())
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(b)
+	f.size += int64(n)
+	return n, err
+}
+
+func (f *rotatingFile) Close() error {
+	return f.file.Close()
 }
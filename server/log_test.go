@@ -0,0 +1,116 @@
+// Copyright (c) 2018-present Mattermost, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLogfmtValue(t *testing.T) {
+	for _, tc := range []struct {
+		value    string
+		expected string
+	}{
+		{"plain", "plain"},
+		{"", `""`},
+		{"with spaces", `"with spaces"`},
+		{"key=value", `"key=value"`},
+		{`say "hi"`, `"say \"hi\""`},
+		{"tab\there", `"tab\there"`},
+		{"two\nlines", `"two\nlines"`},
+	} {
+		if got := logfmtValue(tc.value); got != tc.expected {
+			t.Errorf("%q: got %v, expected %v", tc.value, got, tc.expected)
+		}
+	}
+}
+
+func TestLoggerFormats(t *testing.T) {
+	out := &bytes.Buffer{}
+	l := &logger{level: logLevels[LOG_LEVEL_INFO], format: LOG_FORMAT_LOGFMT, out: nopCloser{out}}
+
+	l.log(LOG_LEVEL_DEBUG, "hidden", nil)
+	if out.Len() != 0 {
+		t.Errorf("expected the debug line to be dropped, got %q", out.String())
+	}
+
+	l.log(LOG_LEVEL_INFO, "[cut] Cutting 5.1.0", LogFields{"user_id": "user", "request_id": "a b=c"})
+	line := out.String()
+	if !strings.HasPrefix(line, "time=") || !strings.HasSuffix(line, ` level=info msg="[cut] Cutting 5.1.0" request_id="a b=c" user_id=user`+"\n") {
+		t.Errorf("unexpected logfmt line %q", line)
+	}
+
+	out.Reset()
+	l.format = LOG_FORMAT_JSON
+	l.log(LOG_LEVEL_ERROR, "Unable to cut", LogFields{"request_id": "a b=c"})
+	entry := map[string]string{}
+	if err := json.Unmarshal(out.Bytes(), &entry); err != nil {
+		t.Fatalf("expected a JSON line, got %q", out.String())
+	}
+	if entry["level"] != "error" || entry["msg"] != "Unable to cut" || entry["request_id"] != "a b=c" || entry["time"] == "" {
+		t.Errorf("unexpected JSON entry %+v", entry)
+	}
+}
+
+func TestRotatingFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "matterbuild")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "matterbuild.log")
+
+	read := func(name string) string {
+		b, _ := ioutil.ReadFile(name)
+		return string(b)
+	}
+
+	f, err := openRotatingFile(name, 0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	f.maxSize = 10
+
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		if _, err := f.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Each line overflows the 10 bytes, only the two most recent backups are kept.
+	if read(name) != "fourth\n" || read(name+".1") != "third\n" || read(name+".2") != "second\n" {
+		t.Errorf("unexpected files: %q, %q, %q", read(name), read(name+".1"), read(name+".2"))
+	}
+	if _, err := os.Stat(name + ".3"); !os.IsNotExist(err) {
+		t.Error("expected the oldest backup to be dropped")
+	}
+
+	// A line bigger than the limit is still written to an empty file.
+	f.maxSize = 3
+	f.Write([]byte("too long\n"))
+	if read(name) != "too long\n" {
+		t.Errorf("expected the long line in its own file, got %q", read(name))
+	}
+
+	// Reopening continues with the size on disk.
+	f.Close()
+	if f, err = openRotatingFile(name, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	f.maxSize = 12
+	f.Write([]byte("next\n"))
+	if read(name) != "next\n" {
+		t.Errorf("expected the file to be rotated on the first write, got %q", read(name))
+	}
+	if read(name+".1") != "fourth\n" {
+		t.Errorf("expected the file to be dropped without MaxBackups, got the backup %q", read(name+".1"))
+	}
+}
@@ -0,0 +1,205 @@
+// Copyright (c) 2018-present Mattermost, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const MATTERMOST_API_PATH = "/api/v4"
+
+type MMPost struct {
+	Id        string                 `json:"id,omitempty"`
+	ChannelId string                 `json:"channel_id,omitempty"`
+	RootId    string                 `json:"root_id,omitempty"`
+	Message   string                 `json:"message"`
+	Props     map[string]interface{} `json:"props,omitempty"`
+	FileIds   []string               `json:"file_ids,omitempty"`
+}
+
+// MattermostClient is a minimal client for the Mattermost REST API, authenticated with a bot or personal access token.
+type MattermostClient struct {
+	URL        string
+	Token      string
+	HTTPClient *http.Client
+}
+
+// NewMattermostClient returns a client for the configured Mattermost server or nil when the API is not configured.
+func NewMattermostClient() *MattermostClient {
+	if Cfg().MattermostURL == "" || Cfg().MattermostBotToken == "" {
+		return nil
+	}
+
+	return &MattermostClient{
+		URL:        strings.TrimRight(Cfg().MattermostURL, "/"),
+		Token:      Cfg().MattermostBotToken,
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (c *MattermostClient) doRequest(method, path string, body interface{}, result interface{}) *AppError {
+	var reqBody []byte
+	if body != nil {
+		var err error
+		if reqBody, err = json.Marshal(body); err != nil {
+			return NewError("Unable to marshal Mattermost API request", err)
+		}
+	}
+
+	req, err := http.NewRequest(method, c.URL+MATTERMOST_API_PATH+path, bytes.NewReader(reqBody))
+	if err != nil {
+		return NewError("Unable to create Mattermost API request", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return NewError("Unable to reach the Mattermost API", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return NewError("Unable to read the Mattermost API response", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return NewError(fmt.Sprintf("Mattermost API returned %v for %v %v", resp.StatusCode, method, path), fmt.Errorf("%s", respBody))
+	}
+
+	if result != nil {
+		if err := json.Unmarshal(respBody, result); err != nil {
+			return NewError("Unable to decode the Mattermost API response", err)
+		}
+	}
+
+	return nil
+}
+
+// CreatePost creates a new post. Setting RootId makes it a reply in that thread.
+func (c *MattermostClient) CreatePost(post *MMPost) (*MMPost, *AppError) {
+	redacted := *post
+	redacted.Message = Redact(post.Message)
+
+	created := &MMPost{}
+	if err := c.doRequest(http.MethodPost, "/posts", &redacted, created); err != nil {
+		LogError("[CreatePost] Unable to create post in channel " + post.ChannelId + " err=" + err.Error())
+		return nil, err
+	}
+
+	return created, nil
+}
+
+// UploadFile uploads a file to the channel and returns its id, to be attached to a post with FileIds.
+func (c *MattermostClient) UploadFile(channelId, fileName string, data []byte) (string, *AppError) {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	writer.WriteField("channel_id", channelId)
+	part, err := writer.CreateFormFile("files", fileName)
+	if err != nil {
+		return "", NewError("Unable to create the file upload", err)
+	}
+	part.Write(data)
+	if err := writer.Close(); err != nil {
+		return "", NewError("Unable to create the file upload", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, c.URL+MATTERMOST_API_PATH+"/files", body)
+	if err != nil {
+		return "", NewError("Unable to create Mattermost API request", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return "", NewError("Unable to reach the Mattermost API", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", NewError("Unable to read the Mattermost API response", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		LogError("[UploadFile] Unable to upload " + fileName + " to channel " + channelId)
+		return "", NewError(fmt.Sprintf("Mattermost API returned %v for POST /files", resp.StatusCode), fmt.Errorf("%s", respBody))
+	}
+
+	var uploaded struct {
+		FileInfos []struct {
+			Id string `json:"id"`
+		} `json:"file_infos"`
+	}
+	if err := json.Unmarshal(respBody, &uploaded); err != nil || len(uploaded.FileInfos) == 0 {
+		return "", NewError("Unable to decode the Mattermost API response", err)
+	}
+
+	return uploaded.FileInfos[0].Id, nil
+}
+
+// ReplyToPost adds a reply to the thread of the given root post.
+func (c *MattermostClient) ReplyToPost(root *MMPost, message string) (*MMPost, *AppError) {
+	return c.CreatePost(&MMPost{
+		ChannelId: root.ChannelId,
+		RootId:    root.Id,
+		Message:   message,
+	})
+}
+
+// UpdatePost replaces the message and props of an existing post.
+func (c *MattermostClient) UpdatePost(post *MMPost) (*MMPost, *AppError) {
+	patch := map[string]interface{}{
+		"message": Redact(post.Message),
+	}
+	if post.Props != nil {
+		patch["props"] = post.Props
+	}
+
+	updated := &MMPost{}
+	if err := c.doRequest(http.MethodPut, "/posts/"+post.Id+"/patch", patch, updated); err != nil {
+		LogError("[UpdatePost] Unable to update post " + post.Id + " err=" + err.Error())
+		return nil, err
+	}
+
+	return updated, nil
+}
+
+// PostAttachmentProps returns post props rendering a single message attachment, the same way slash responses are enriched.
+func PostAttachmentProps(title, text, color string) map[string]interface{} {
+	title = Redact(title)
+	text = Redact(text)
+
+	return map[string]interface{}{
+		"attachments": []Attachment{{
+			Fallback:   text,
+			Color:      color,
+			Text:       text,
+			Title:      title,
+			AuthorName: "Matterbuild",
+			AuthorIcon: "https://www.mattermost.org/wp-content/uploads/2016/04/icon.png",
+		}},
+	}
+}
+
+// postNotification posts an attachment to the channel, if the Mattermost API and the channel are configured.
+func postNotification(channelId, title, msg, color string) {
+	client := NewMattermostClient()
+	if client == nil || channelId == "" {
+		return
+	}
+
+	client.CreatePost(&MMPost{
+		ChannelId: channelId,
+		Props:     PostAttachmentProps(title, msg, color),
+	})
+}
@@ -0,0 +1,231 @@
+// Copyright (c) 2018-present Mattermost, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeMattermost records the posts created and updated through the Mattermost API.
+type fakeMattermost struct {
+	*httptest.Server
+
+	sync.Mutex
+	posts   []*MMPost
+	patches []*MMPost
+	files   []string
+	fail    bool
+}
+
+func newFakeMattermost() *fakeMattermost {
+	f := &fakeMattermost{}
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.Lock()
+		defer f.Unlock()
+
+		if f.fail || r.Header.Get("Authorization") != "Bearer bot-token" {
+			http.Error(w, `{"message": "failed"}`, http.StatusInternalServerError)
+			return
+		}
+
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == MATTERMOST_API_PATH+"/posts":
+			post := &MMPost{}
+			json.NewDecoder(r.Body).Decode(post)
+			post.Id = fmt.Sprintf("post%v", len(f.posts)+1)
+			f.posts = append(f.posts, post)
+			json.NewEncoder(w).Encode(post)
+		case r.Method == http.MethodPut && strings.HasSuffix(r.URL.Path, "/patch"):
+			post := &MMPost{}
+			json.NewDecoder(r.Body).Decode(post)
+			post.Id = strings.Split(strings.TrimPrefix(r.URL.Path, MATTERMOST_API_PATH+"/posts/"), "/")[0]
+			f.patches = append(f.patches, post)
+			json.NewEncoder(w).Encode(post)
+		case r.Method == http.MethodPost && r.URL.Path == MATTERMOST_API_PATH+"/files":
+			_, header, err := r.FormFile("files")
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			f.files = append(f.files, header.Filename)
+			fmt.Fprintf(w, `{"file_infos": [{"id": "file%v"}]}`, len(f.files))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	return f
+}
+
+func (f *fakeMattermost) config(c *MatterbuildConfig) *MatterbuildConfig {
+	c.MattermostURL = f.URL
+	c.MattermostBotToken = "bot-token"
+	return c
+}
+
+func (f *fakeMattermost) Posts() []*MMPost {
+	f.Lock()
+	defer f.Unlock()
+	return append([]*MMPost{}, f.posts...)
+}
+
+func (f *fakeMattermost) Patches() []*MMPost {
+	f.Lock()
+	defer f.Unlock()
+	return append([]*MMPost{}, f.patches...)
+}
+
+// waitForPosts waits until count posts were created, the progress and notifications are posted in the background.
+func (f *fakeMattermost) waitForPosts(t *testing.T, count int) []*MMPost {
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if posts := f.Posts(); len(posts) >= count {
+			return posts
+		}
+	}
+	t.Fatalf("expected %v posts, got %v", count, len(f.Posts()))
+	return nil
+}
+
+// attachmentText returns the text of the attachment of a post created with PostAttachmentProps.
+func attachmentText(post *MMPost) string {
+	attachments, _ := post.Props["attachments"].([]interface{})
+	if len(attachments) == 0 {
+		return ""
+	}
+	attachment, _ := attachments[0].(map[string]interface{})
+	text, _ := attachment["text"].(string)
+	return text
+}
+
+func TestMattermostClient(t *testing.T) {
+	mattermost := newFakeMattermost()
+	defer mattermost.Close()
+
+	defer setTestConfig(t, &MatterbuildConfig{})()
+	if NewMattermostClient() != nil {
+		t.Fatal("expected no client without the API settings")
+	}
+
+	currentConfig.Store(mattermost.config(&MatterbuildConfig{}))
+	client := NewMattermostClient()
+
+	root, err := client.CreatePost(&MMPost{ChannelId: "channel", Message: "Release 5.1.0"})
+	if err != nil || root.Id == "" {
+		t.Fatalf("expected the post to be created, got %+v (err=%v)", root, err)
+	}
+	if _, err := client.ReplyToPost(root, "Release job: success"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.UpdatePost(&MMPost{Id: root.Id, Props: PostAttachmentProps("Release", "Done", "#86c323")}); err != nil {
+		t.Fatal(err)
+	}
+	if fileId, err := client.UploadFile("channel", "changelog.md", []byte("changes")); err != nil || fileId != "file1" {
+		t.Fatalf("expected the file to be uploaded, got %v (err=%v)", fileId, err)
+	}
+
+	posts := mattermost.Posts()
+	if len(posts) != 2 || posts[1].RootId != root.Id || posts[1].ChannelId != "channel" {
+		t.Errorf("expected the reply in the thread of the post, got %+v", posts)
+	}
+	if patches := mattermost.Patches(); len(patches) != 1 || patches[0].Id != root.Id || attachmentText(patches[0]) != "Done" {
+		t.Errorf("expected the post to be updated, got %+v", patches)
+	}
+
+	mattermost.Lock()
+	mattermost.fail = true
+	mattermost.Unlock()
+	if _, err := client.CreatePost(&MMPost{ChannelId: "channel"}); err == nil {
+		t.Error("expected the API error to be returned")
+	}
+}
+
+func TestReleaseProgress(t *testing.T) {
+	mattermost := newFakeMattermost()
+	defer mattermost.Close()
+	defer setTestConfig(t, mattermost.config(&MatterbuildConfig{}))()
+
+	if progress := NewReleaseProgress("", "Release 5.1.0", nil); progress != nil {
+		t.Error("expected no progress without a channel")
+	}
+
+	progress := NewReleaseProgress("channel", "Release 5.1.0", []string{STEP_PRECHECKS, STEP_RELEASE_JOB, STEP_RC_TESTING})
+	if progress == nil {
+		t.Fatal("expected the progress post to be created")
+	}
+
+	progress.StepSucceeded(STEP_PRECHECKS, "")
+	progress.StepFailed(STEP_RELEASE_JOB, "Jenkins result: FAILURE")
+	progress.SkipRemaining("The release job failed")
+
+	// The progress post and the three replies.
+	posts := mattermost.waitForPosts(t, 4)
+	for _, reply := range posts[1:] {
+		if reply.RootId != posts[0].Id {
+			t.Errorf("expected the reply %q in the thread of the progress", reply.Message)
+		}
+	}
+	if !strings.Contains(posts[2].Message, "Jenkins result: FAILURE") || !strings.Contains(posts[3].Message, STEP_RC_TESTING) {
+		t.Errorf("the replies are out of order: %q, %q", posts[2].Message, posts[3].Message)
+	}
+
+	var last *MMPost
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if patches := mattermost.Patches(); len(patches) > 0 {
+			last = patches[len(patches)-1]
+			if strings.Contains(attachmentText(last), stepIcons[STEP_SKIPPED]) {
+				break
+			}
+		}
+	}
+	if last == nil || !strings.Contains(attachmentText(last), stepIcons[STEP_SKIPPED]+" **"+STEP_RC_TESTING+"**") {
+		t.Errorf("expected the post to show the skipped step, got %+v", last)
+	}
+
+	// Nothing is left to skip.
+	progress.SkipRemaining("Again")
+
+	// A nil progress, when the API isn't configured, does nothing.
+	var none *ReleaseProgress
+	none.StepRunning(STEP_PRECHECKS, "")
+	none.SkipRemaining("")
+}
+
+func TestReleaseProgressDoesNotWaitForMattermost(t *testing.T) {
+	blocked := make(chan struct{})
+	mattermost := newFakeMattermost()
+	defer mattermost.Close()
+	defer setTestConfig(t, mattermost.config(&MatterbuildConfig{}))()
+
+	progress := NewReleaseProgress("channel", "Release 5.1.0", []string{STEP_PRECHECKS})
+	if progress == nil {
+		t.Fatal("expected the progress post to be created")
+	}
+
+	// Mattermost stops answering.
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-blocked
+	}))
+	defer slow.Close()
+	defer close(blocked)
+	progress.client = &MattermostClient{URL: slow.URL, Token: "bot-token", HTTPClient: &http.Client{}}
+
+	done := make(chan struct{})
+	go func() {
+		progress.StepRunning(STEP_PRECHECKS, "")
+		progress.StepSucceeded(STEP_PRECHECKS, "")
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("the steps waited for Mattermost")
+	}
+}
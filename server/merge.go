@@ -9,22 +9,19 @@ import (
 	"time"
 
 	"github.com/google/go-github/github"
-	"golang.org/x/oauth2"
 )
 
 var client *github.Client
 var ctx = context.Background()
 
-func CreateMergeAndPr(branchToMerge string) (string, *AppError) {
-	ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: Cfg.GithubAccessToken})
-	tc := oauth2.NewClient(ctx, ts)
-	client = github.NewClient(tc)
+func CreateMergeAndPr(ctx context.Context, branchToMerge string) (string, *AppError) {
+	client = githubClient()
 
 	var repoError []string
 	var prs []string
-	for _, repo := range Cfg.Repositories {
-		if pr, err := createMergeAndPr(repo, branchToMerge); err != nil {
-			LogError("Error while creating the merge: " + err.Error())
+	for _, repo := range Cfg().Repositories {
+		if pr, err := createMergeAndPr(ctx, repo, branchToMerge); err != nil {
+			LogErrorContext(ctx, "Error while creating the merge: "+err.Error())
 			repoError = append(repoError, err.Error())
 		} else {
 			prs = append(prs, pr)
@@ -45,20 +42,22 @@ func CreateMergeAndPr(branchToMerge string) (string, *AppError) {
 	return msg, nil
 }
 
-func createMergeAndPr(repository *Repository, branchToMerge string) (string, *AppError) {
+func createMergeAndPr(ctx context.Context, repository *Repository, branchToMerge string) (string, *AppError) {
 	refMaster := "refs/heads/master"
 
 	branchRef := fmt.Sprintf("refs/heads/%s", branchToMerge)
 	_, _, err := client.Git.GetRef(ctx, repository.Owner, repository.Name, branchRef)
 	if err != nil {
+		githubAPIErrorsTotal.Inc(repository.Owner+"/"+repository.Name, "get_ref")
 		return "", NewError("Error when getting the release branch ref. Please check if that exists", err)
 	}
 
 	masterRef, _, err := client.Git.GetRef(ctx, repository.Owner, repository.Name, refMaster)
 	if err != nil {
+		githubAPIErrorsTotal.Inc(repository.Owner+"/"+repository.Name, "get_ref")
 		return "", NewError("Error when getting the master ref.", err)
 	}
-	LogInfo("Master Ref: " + *masterRef.Object.SHA + " for repo: " + repository.Name)
+	LogInfoContext(ctx, "Master Ref: "+*masterRef.Object.SHA+" for repo: "+repository.Name)
 
 	timeNow := time.Now().Format("20060102150405")
 	newBranchName := fmt.Sprintf("refs/heads/merge-%s-%s", branchToMerge, timeNow)
@@ -71,14 +70,15 @@ func createMergeAndPr(repository *Repository, branchToMerge string) (string, *Ap
 
 	newBranch, resp, err := client.Git.CreateRef(ctx, repository.Owner, repository.Name, newBranch)
 	if err != nil {
+		githubAPIErrorsTotal.Inc(repository.Owner+"/"+repository.Name, "create_ref")
 		return "", NewError("Error when creating the new branch.", err)
 	}
 	if resp.Response.StatusCode != 201 {
-		msg := fmt.Sprintf("Error when creating the branch", repository.Name)
-		LogInfo(msg)
+		msg := fmt.Sprintf("Error when creating the branch for %v", repository.Name)
+		LogInfoContext(ctx, msg)
 		return msg, nil
 	}
-	LogInfo("New Branch Ref: " + *newBranch.Ref + " for repo: " + repository.Name)
+	LogInfoContext(ctx, "New Branch Ref: "+*newBranch.Ref+" for repo: "+repository.Name)
 
 	commitMessage := fmt.Sprintf("Merge %s", branchToMerge)
 	newMerge := &github.RepositoryMergeRequest{
@@ -89,21 +89,22 @@ func createMergeAndPr(repository *Repository, branchToMerge string) (string, *Ap
 
 	merge, resp, err := client.Repositories.Merge(ctx, repository.Owner, repository.Name, newMerge)
 	if err != nil {
+		githubAPIErrorsTotal.Inc(repository.Owner+"/"+repository.Name, "merge")
 		msg := fmt.Sprintf("Error when merging the branch. Please perform the merge manually for %s.", repository.Name)
-		LogError(msg)
+		LogErrorContext(ctx, msg)
 		return "", NewError(msg, err)
 	}
 	if resp.Response.StatusCode == 204 {
 		msg := fmt.Sprintf("Nothing to Merge for **%s**", repository.Name)
-		LogInfo(msg)
+		LogInfoContext(ctx, msg)
 		return msg, nil
 	}
 	if resp.Response.StatusCode == 409 {
 		msg := fmt.Sprintf("Error when Merging. Please perform the merge manually for %s.", repository.Name)
-		LogInfo(msg)
+		LogInfoContext(ctx, msg)
 		return msg, nil
 	}
-	LogInfo("Merge created: " + *merge.HTMLURL + " for repo: " + repository.Name)
+	LogInfoContext(ctx, "Merge created: "+*merge.HTMLURL+" for repo: "+repository.Name)
 
 	title := fmt.Sprintf("Merge %s-%s", branchToMerge, timeNow)
 	prDescription := fmt.Sprintf("#### Summary \n Merge from `%s` to `master`", branchToMerge)
@@ -117,9 +118,10 @@ func createMergeAndPr(repository *Repository, branchToMerge string) (string, *Ap
 
 	pr, _, err := client.PullRequests.Create(ctx, repository.Owner, repository.Name, newPR)
 	if err != nil {
+		githubAPIErrorsTotal.Inc(repository.Owner+"/"+repository.Name, "create_pull_request")
 		return "", NewError("Error when creating the PR.", err)
 	}
-	LogInfo("PR created: " + pr.GetHTMLURL() + " for repo: " + repository.Name)
+	LogInfoContext(ctx, "PR created: "+pr.GetHTMLURL()+" for repo: "+repository.Name)
 
 	return pr.GetHTMLURL(), nil
 }
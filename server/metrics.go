@@ -0,0 +1,126 @@
+// Copyright (c) 2018-present Mattermost, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// The metrics below are exposed on /metrics of the MetricsListenAddress.
+var (
+	slashCommandsTotal = newCounterVec(
+		"matterbuild_slash_commands_total",
+		"Commands received through slash commands and outgoing webhooks.",
+		"subcommand", "result", "permission")
+
+	jenkinsAPIDuration = newHistogramVec(
+		"matterbuild_jenkins_api_duration_seconds",
+		"Latency of the calls to the Jenkins API.",
+		[]float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+		"operation", "result")
+
+	jenkinsJobDuration = newHistogramVec(
+		"matterbuild_jenkins_job_duration_seconds",
+		"Duration of the Jenkins builds matterbuild waited for.",
+		[]float64{60, 300, 600, 1200, 1800, 3600, 7200, 14400},
+		"job", "result")
+
+	githubAPIErrorsTotal = newCounterVec(
+		"matterbuild_github_api_errors_total",
+		"Errors returned by the GitHub API while merging release branches.",
+		"repository", "operation")
+
+	backgroundOperationsGauge = prometheus.NewGaugeFunc(
+		prometheus.GaugeOpts{
+			Name: "matterbuild_background_operations",
+			Help: "Operations currently running in the background.",
+		},
+		func() float64 { return float64(len(InFlightOperations())) })
+)
+
+var metricsRegistry = prometheus.NewRegistry()
+
+func init() {
+	metricsRegistry.MustRegister(
+		slashCommandsTotal,
+		jenkinsAPIDuration,
+		jenkinsJobDuration,
+		githubAPIErrorsTotal,
+		backgroundOperationsGauge,
+	)
+}
+
+// newMetricsServer serves /metrics on the MetricsListenAddress, it returns nil if it isn't configured.
+// Metrics have their own listener so they are never exposed with the slash command endpoint.
+func newMetricsServer() *http.Server {
+	if Cfg().MetricsListenAddress == "" {
+		return nil
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(metricsRegistry, promhttp.HandlerOpts{}))
+
+	return &http.Server{
+		Addr:    Cfg().MetricsListenAddress,
+		Handler: mux,
+	}
+}
+
+// observeJenkinsCall records the latency of a Jenkins API call started at start.
+func observeJenkinsCall(operation string, start time.Time, err error) {
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	jenkinsAPIDuration.Observe(time.Since(start).Seconds(), operation, result)
+}
+
+// counterVec is a prometheus.CounterVec that drops the samples with the wrong number of labels instead of
+// panicking in the middle of a request.
+type counterVec struct {
+	*prometheus.CounterVec
+	name string
+}
+
+func newCounterVec(name, help string, labelNames ...string) *counterVec {
+	return &counterVec{
+		CounterVec: prometheus.NewCounterVec(prometheus.CounterOpts{Name: name, Help: help}, labelNames),
+		name:       name,
+	}
+}
+
+func (c *counterVec) Inc(labelValues ...string) {
+	counter, err := c.GetMetricWithLabelValues(labelValues...)
+	if err != nil {
+		LogError("[counterVec.Inc] Dropping a sample of " + c.name + " err=" + err.Error())
+		return
+	}
+	counter.Inc()
+}
+
+// histogramVec is a prometheus.HistogramVec that drops the samples with the wrong number of labels.
+type histogramVec struct {
+	*prometheus.HistogramVec
+	name string
+}
+
+func newHistogramVec(name, help string, buckets []float64, labelNames ...string) *histogramVec {
+	return &histogramVec{
+		HistogramVec: prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: name, Help: help, Buckets: buckets}, labelNames),
+		name:         name,
+	}
+}
+
+func (h *histogramVec) Observe(value float64, labelValues ...string) {
+	histogram, err := h.GetMetricWithLabelValues(labelValues...)
+	if err != nil {
+		LogError("[histogramVec.Observe] Dropping a sample of " + h.name + " err=" + err.Error())
+		return
+	}
+	histogram.Observe(value)
+}
@@ -0,0 +1,183 @@
+// Copyright (c) 2018-present Mattermost, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package server
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// parseCIDRs parses a list of CIDRs, plain IPs are treated as a single host network.
+func parseCIDRs(cidrs []string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, cidr := range cidrs {
+		if !strings.Contains(cidr, "/") {
+			if ip := net.ParseIP(cidr); ip != nil && ip.To4() != nil {
+				cidr += "/32"
+			} else {
+				cidr += "/128"
+			}
+		}
+
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+		networks = append(networks, network)
+	}
+
+	return networks, nil
+}
+
+func ipInNetworks(ip string, cidrs []string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+
+	networks, err := parseCIDRs(cidrs)
+	if err != nil {
+		LogError("[ipInNetworks] Invalid CIDR in config err=" + err.Error())
+		return false
+	}
+
+	for _, network := range networks {
+		if network.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// clientIP returns the address of the client. X-Forwarded-For is only honoured when the request comes
+// through one of the TrustedProxies, and the first address that isn't a trusted proxy is the client.
+func clientIP(r *http.Request) string {
+	ip := remoteIP(r)
+	if !ipInNetworks(ip, Cfg().TrustedProxies) {
+		return ip
+	}
+
+	forwarded := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(forwarded[i])
+		if hop == "" {
+			continue
+		}
+		ip = hop
+		if !ipInNetworks(hop, Cfg().TrustedProxies) {
+			break
+		}
+	}
+
+	return ip
+}
+
+// requestScheme returns the scheme the client used, taking X-Forwarded-Proto from trusted proxies into account.
+func requestScheme(r *http.Request) string {
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" && ipInNetworks(remoteIP(r), Cfg().TrustedProxies) {
+		return proto
+	}
+
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// sourceFilteredPaths are the endpoints Mattermost calls, the only ones AllowedSourceCIDRs applies to.
+// Jenkins and GitHub call the hooks from their own networks, the hooks check their token or signature instead.
+var sourceFilteredPaths = []string{"/slash_command", "/outgoing_webhook"}
+
+// filterRequests refuses the commands that don't come from the AllowedSourceCIDRs and writes an audit log line for the others.
+func filterRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := clientIP(r)
+
+		if len(Cfg().AllowedSourceCIDRs) > 0 && containsOrEmpty(sourceFilteredPaths, r.URL.Path) && !ipInNetworks(ip, Cfg().AllowedSourceCIDRs) {
+			LogError("[filterRequests] Refused " + r.Method + " " + r.URL.Path + " from " + ip)
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		r = r.WithContext(WithLogFields(r.Context(), LogFields{"request_id": NewRequestId()}))
+		LogInfoContext(r.Context(), "[filterRequests] "+r.Method+" "+requestScheme(r)+"://"+r.Host+r.URL.Path+" from "+ip)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// certificateReloader serves the TLS certificate from disk and reloads it when the files change,
+// so renewed certificates are picked up without a restart.
+type certificateReloader struct {
+	sync.Mutex
+	certFile  string
+	keyFile   string
+	cert      *tls.Certificate
+	modTime   time.Time
+	checkedAt time.Time
+}
+
+func newCertificateReloader(certFile, keyFile string) (*certificateReloader, *AppError) {
+	reloader := &certificateReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+	}
+
+	if err := reloader.reload(); err != nil {
+		return nil, err
+	}
+
+	return reloader, nil
+}
+
+func (c *certificateReloader) latestModTime() time.Time {
+	var latest time.Time
+	for _, file := range []string{c.certFile, c.keyFile} {
+		if info, err := os.Stat(file); err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest
+}
+
+func (c *certificateReloader) reload() *AppError {
+	modTime := c.latestModTime()
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return NewError("Unable to load the TLS certificate "+c.certFile, err)
+	}
+
+	c.cert = &cert
+	c.modTime = modTime
+	return nil
+}
+
+func (c *certificateReloader) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	if time.Since(c.checkedAt) > CONFIG_WATCH_INTERVAL {
+		c.checkedAt = time.Now()
+		if c.latestModTime().After(c.modTime) {
+			LogInfo("[GetCertificate] TLS certificate changed, reloading " + c.certFile)
+			if err := c.reload(); err != nil {
+				LogError("[GetCertificate] Keeping the previous certificate. err=" + err.Error())
+			}
+		}
+	}
+
+	return c.cert, nil
+}
@@ -0,0 +1,203 @@
+// Copyright (c) 2018-present Mattermost, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseCIDRs(t *testing.T) {
+	for _, tc := range []struct {
+		cidr     string
+		contains string
+		excludes string
+		invalid  bool
+	}{
+		{cidr: "10.0.0.0/8", contains: "10.1.2.3", excludes: "11.0.0.1"},
+		{cidr: "192.168.1.10", contains: "192.168.1.10", excludes: "192.168.1.11"},
+		{cidr: "2001:db8::/32", contains: "2001:db8::1", excludes: "2001:db9::1"},
+		{cidr: "2001:db8::1", contains: "2001:db8::1", excludes: "2001:db8::2"},
+		{cidr: "10.0.0.0/33", invalid: true},
+		{cidr: "not-an-ip", invalid: true},
+	} {
+		networks, err := parseCIDRs([]string{tc.cidr})
+		if tc.invalid {
+			if err == nil {
+				t.Errorf("%q: expected an error", tc.cidr)
+			}
+			continue
+		}
+		if err != nil || len(networks) != 1 {
+			t.Errorf("%q: unexpected error %v", tc.cidr, err)
+			continue
+		}
+		if !ipInNetworks(tc.contains, []string{tc.cidr}) || ipInNetworks(tc.excludes, []string{tc.cidr}) {
+			t.Errorf("%q: expected %v in and %v out of the network", tc.cidr, tc.contains, tc.excludes)
+		}
+	}
+
+	if ipInNetworks("10.0.0.1", []string{"10.0.0.0/8", "bad"}) {
+		t.Error("expected an invalid config to match nothing")
+	}
+	if ipInNetworks("unknown", []string{"10.0.0.0/8"}) {
+		t.Error("expected an invalid address to match nothing")
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	defer setTestConfig(t, &MatterbuildConfig{TrustedProxies: []string{"10.0.0.0/8"}})()
+
+	for _, tc := range []struct {
+		name       string
+		remoteAddr string
+		forwarded  string
+		expected   string
+	}{
+		{name: "direct", remoteAddr: "203.0.113.5:1234", expected: "203.0.113.5"},
+		{name: "untrusted proxy", remoteAddr: "203.0.113.5:1234", forwarded: "198.51.100.7", expected: "203.0.113.5"},
+		{name: "trusted proxy", remoteAddr: "10.0.0.1:1234", forwarded: "198.51.100.7", expected: "198.51.100.7"},
+		{name: "spoofed first hop", remoteAddr: "10.0.0.1:1234", forwarded: "1.2.3.4, 198.51.100.7", expected: "198.51.100.7"},
+		{name: "chain of trusted proxies", remoteAddr: "10.0.0.1:1234", forwarded: "198.51.100.7, 10.0.0.2,", expected: "198.51.100.7"},
+		{name: "only trusted proxies", remoteAddr: "10.0.0.1:1234", forwarded: "10.0.0.3, 10.0.0.2", expected: "10.0.0.3"},
+		{name: "trusted proxy without the header", remoteAddr: "10.0.0.1:1234", expected: "10.0.0.1"},
+		{name: "no port", remoteAddr: "203.0.113.5", expected: "203.0.113.5"},
+	} {
+		r := httptest.NewRequest(http.MethodPost, "/slash_command", nil)
+		r.RemoteAddr = tc.remoteAddr
+		if tc.forwarded != "" {
+			r.Header.Set("X-Forwarded-For", tc.forwarded)
+		}
+		if got := clientIP(r); got != tc.expected {
+			t.Errorf("%v: got %v, expected %v", tc.name, got, tc.expected)
+		}
+	}
+}
+
+func TestFilterRequests(t *testing.T) {
+	defer setTestConfig(t, &MatterbuildConfig{AllowedSourceCIDRs: []string{"10.0.0.0/8"}})()
+
+	handler := filterRequests(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if LogFieldsFrom(r.Context())["request_id"] == "" {
+			t.Errorf("%v: expected a request id", r.URL.Path)
+		}
+	}))
+
+	for _, tc := range []struct {
+		path       string
+		remoteAddr string
+		expected   int
+	}{
+		{path: "/slash_command", remoteAddr: "10.1.2.3:1234", expected: http.StatusOK},
+		{path: "/slash_command", remoteAddr: "203.0.113.5:1234", expected: http.StatusForbidden},
+		{path: "/outgoing_webhook", remoteAddr: "203.0.113.5:1234", expected: http.StatusForbidden},
+		{path: "/hooks/jenkins", remoteAddr: "203.0.113.5:1234", expected: http.StatusOK},
+		{path: "/hooks/github", remoteAddr: "203.0.113.5:1234", expected: http.StatusOK},
+	} {
+		r := httptest.NewRequest(http.MethodPost, tc.path, nil)
+		r.RemoteAddr = tc.remoteAddr
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != tc.expected {
+			t.Errorf("%v from %v: got status %v, expected %v", tc.path, tc.remoteAddr, w.Code, tc.expected)
+		}
+	}
+}
+
+// writeCertificate writes a self-signed certificate for the common name and its key.
+func writeCertificate(t *testing.T, certFile, keyFile, commonName string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func certificateName(t *testing.T, cert *tls.Certificate) string {
+	parsed, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed.Subject.CommonName
+}
+
+func TestCertificateReloader(t *testing.T) {
+	defer setTestConfig(t, &MatterbuildConfig{})()
+	certFile := filepath.Join(Cfg().DataDirectory, "cert.pem")
+	keyFile := filepath.Join(Cfg().DataDirectory, "key.pem")
+
+	if _, err := newCertificateReloader(certFile, keyFile); err == nil {
+		t.Error("expected an error without the certificate")
+	}
+
+	writeCertificate(t, certFile, keyFile, "first")
+	reloader, err := newCertificateReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The files are only checked once per interval.
+	writeCertificate(t, certFile, keyFile, "renewed")
+	later := time.Now().Add(time.Minute)
+	for _, file := range []string{certFile, keyFile} {
+		if err := os.Chtimes(file, later, later); err != nil {
+			t.Fatal(err)
+		}
+	}
+	reloader.checkedAt = time.Now()
+	if cert, _ := reloader.GetCertificate(nil); certificateName(t, cert) != "first" {
+		t.Error("expected the certificate to be checked only once per interval")
+	}
+
+	reloader.checkedAt = time.Time{}
+	if cert, _ := reloader.GetCertificate(nil); certificateName(t, cert) != "renewed" {
+		t.Error("expected the renewed certificate to be loaded")
+	}
+
+	// A broken renewal keeps the previous certificate.
+	if err := ioutil.WriteFile(certFile, []byte("broken"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	later = later.Add(time.Minute)
+	if err := os.Chtimes(certFile, later, later); err != nil {
+		t.Fatal(err)
+	}
+	reloader.checkedAt = time.Time{}
+	if cert, _ := reloader.GetCertificate(nil); certificateName(t, cert) != "renewed" {
+		t.Error("expected the previous certificate to be kept")
+	}
+}
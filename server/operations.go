@@ -0,0 +1,181 @@
+// Copyright (c) 2018-present Mattermost, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package server
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const PENDING_OPERATIONS_FILE = "pending_operations.json"
+
+const DEFAULT_SHUTDOWN_TIMEOUT_SECONDS = 60
+
+// Operation is work that outlives the command response, like the release chain started by cut
+// or a command waiting for a Jenkins build.
+type Operation struct {
+	Id        string
+	Name      string
+	UserId    string
+	RequestId string
+	StartedAt time.Time
+	State     string
+	Details   map[string]string
+
+	interrupted bool
+}
+
+var operations = map[string]*Operation{}
+var interruptedOperations []*Operation
+var operationsLock sync.Mutex
+var operationsCount int64
+
+var shuttingDown int32
+
+// StartOperation registers an in-flight operation. Finish must be called when it is done. The request id
+// groups the operations started by the same command, e.g. a release and the Jenkins builds it waits for.
+func StartOperation(name string, userId string, requestId string, details map[string]string) *Operation {
+	op := &Operation{
+		Id:        strconv.FormatInt(atomic.AddInt64(&operationsCount, 1), 10),
+		Name:      name,
+		UserId:    userId,
+		RequestId: requestId,
+		StartedAt: time.Now(),
+		State:     "started",
+		Details:   details,
+	}
+
+	operationsLock.Lock()
+	operations[op.Id] = op
+	operationsLock.Unlock()
+
+	return op
+}
+
+// Finish unregisters the operation. Operations stopped at a checkpoint are kept to be persisted as pending.
+func (op *Operation) Finish() {
+	operationsLock.Lock()
+	delete(operations, op.Id)
+	if op.interrupted {
+		interruptedOperations = append(interruptedOperations, op)
+	}
+	operationsLock.Unlock()
+}
+
+// SetState records what the operation is currently doing.
+func (op *Operation) SetState(state string) {
+	operationsLock.Lock()
+	op.State = state
+	operationsLock.Unlock()
+}
+
+// Checkpoint records the state the operation is about to enter. Checkpoints are the safe points where an
+// operation can be interrupted, it returns false when the server is shutting down and the operation must stop.
+func (op *Operation) Checkpoint(state string) bool {
+	operationsLock.Lock()
+	defer operationsLock.Unlock()
+
+	op.State = state
+	if IsShuttingDown() {
+		LogInfo("[Checkpoint] Stopping operation " + op.Name + " for shutdown before: " + state)
+		op.interrupted = true
+		return false
+	}
+	return true
+}
+
+// InFlightOperations returns a copy of the registered operations, oldest first.
+func InFlightOperations() []*Operation {
+	operationsLock.Lock()
+	defer operationsLock.Unlock()
+
+	list := make([]*Operation, 0, len(operations))
+	for _, op := range operations {
+		copied := *op
+		list = append(list, &copied)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].StartedAt.Before(list[j].StartedAt) })
+
+	return list
+}
+
+func IsShuttingDown() bool {
+	return atomic.LoadInt32(&shuttingDown) == 1
+}
+
+func startShutdown() {
+	atomic.StoreInt32(&shuttingDown, 1)
+}
+
+// WaitForOperations waits until no operation is running or the timeout expires. A zero timeout waits forever.
+// It returns the operations still running.
+func WaitForOperations(timeout time.Duration) []*Operation {
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+
+	for {
+		remaining := InFlightOperations()
+		if len(remaining) == 0 || (timeout > 0 && time.Now().After(deadline)) {
+			return remaining
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+func shutdownTimeout() time.Duration {
+	if Cfg().ShutdownTimeoutSeconds > 0 {
+		return time.Duration(Cfg().ShutdownTimeoutSeconds) * time.Second
+	}
+	return DEFAULT_SHUTDOWN_TIMEOUT_SECONDS * time.Second
+}
+
+// drainOperations stops new commands and gives the in-flight operations the shutdown timeout to finish
+// or reach a checkpoint. Whatever is left is persisted so it can be reported after the restart.
+func drainOperations() {
+	startShutdown()
+
+	remaining := WaitForOperations(shutdownTimeout())
+
+	operationsLock.Lock()
+	remaining = append(append([]*Operation{}, interruptedOperations...), remaining...)
+	operationsLock.Unlock()
+
+	if len(remaining) == 0 {
+		removeData(PENDING_OPERATIONS_FILE)
+		return
+	}
+
+	for _, op := range remaining {
+		LogError("[drainOperations] Operation " + op.Name + " still pending at shutdown. State: " + op.State)
+	}
+	if err := saveJSON(PENDING_OPERATIONS_FILE, remaining); err != nil {
+		LogError("[drainOperations] Unable to persist the pending operations err=" + err.Error())
+	}
+}
+
+// reportPendingOperations reports the operations that were interrupted by the previous shutdown.
+func reportPendingOperations() {
+	var pending []*Operation
+	if found, err := loadJSON(PENDING_OPERATIONS_FILE, &pending); err != nil {
+		LogError("[reportPendingOperations] err=" + err.Error())
+		return
+	} else if !found || len(pending) == 0 {
+		return
+	}
+
+	msg := "These operations were interrupted by the last shutdown and need to be checked manually:\n"
+	for _, op := range pending {
+		LogError("[reportPendingOperations] Operation " + op.Name + " was interrupted. State: " + op.State)
+		msg += fmt.Sprintf("* **%v** started at %v by %v, last state: %v\n", op.Name, op.StartedAt.Format(time.RFC1123), op.UserId, op.State)
+	}
+	postNotification(Cfg().ReleaseChannelId, "Interrupted Operations", msg, "#e20025")
+
+	removeData(PENDING_OPERATIONS_FILE)
+}
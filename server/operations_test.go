@@ -0,0 +1,79 @@
+// Copyright (c) 2018-present Mattermost, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package server
+
+import (
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDrainOperations(t *testing.T) {
+	mattermost := newFakeMattermost()
+	defer mattermost.Close()
+	defer setTestConfig(t, mattermost.config(&MatterbuildConfig{
+		ShutdownTimeoutSeconds: 1,
+		ReleaseChannelId:       "channel",
+		AllowedTokens:          []string{"token"},
+		AllowedUsers:           []string{"user"},
+	}))()
+	defer func() {
+		atomic.StoreInt32(&shuttingDown, 0)
+		operationsLock.Lock()
+		operations = map[string]*Operation{}
+		interruptedOperations = nil
+		operationsLock.Unlock()
+	}()
+
+	// Finishes while draining.
+	quick := StartOperation("quick", "user", "request1", nil)
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		quick.Finish()
+	}()
+
+	// Stops at its next checkpoint.
+	release := StartOperation("release", "user", "request2", nil)
+	go func() {
+		defer release.Finish()
+		for release.Checkpoint("waiting for the release job") {
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+
+	// Never finishes.
+	stuck := StartOperation("stuck", "user", "request3", nil)
+	stuck.SetState("waiting for Jenkins")
+
+	drainOperations()
+
+	if !IsShuttingDown() {
+		t.Error("expected the server to be shutting down")
+	}
+	if response, rejected := runSlashCommand(&MMSlashCommand{Token: "token", UserId: "user", Command: "/matterbuild", Text: "lock status"}, "test"); !rejected || response == nil {
+		t.Error("expected new commands to be refused while shutting down")
+	}
+
+	var pending []*Operation
+	if found, err := loadJSON(PENDING_OPERATIONS_FILE, &pending); err != nil || !found {
+		t.Fatalf("expected the pending operations to be persisted (err=%v)", err)
+	}
+	if len(pending) != 2 || pending[0].Name != "release" || pending[1].Name != "stuck" {
+		t.Fatalf("expected the interrupted and the running operations, got %+v", pending)
+	}
+	if pending[0].State != "waiting for the release job" || pending[1].State != "waiting for Jenkins" {
+		t.Errorf("expected the last states to be kept, got %q and %q", pending[0].State, pending[1].State)
+	}
+
+	// After the restart they are reported once.
+	reportPendingOperations()
+	posts := mattermost.waitForPosts(t, 1)
+	if text := attachmentText(posts[0]); !strings.Contains(text, "**release**") || !strings.Contains(text, "**stuck**") || strings.Contains(text, "quick") {
+		t.Errorf("expected the pending operations to be reported, got %q", text)
+	}
+	if found, _ := loadJSON(PENDING_OPERATIONS_FILE, &pending); found {
+		t.Error("expected the pending operations to be removed once reported")
+	}
+}
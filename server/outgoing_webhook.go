@@ -0,0 +1,134 @@
+// Copyright (c) 2018-present Mattermost, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package server
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/schema"
+	"github.com/julienschmidt/httprouter"
+)
+
+const OUTGOING_RESPONSE_COMMENT = "comment"
+
+type MMOutgoingWebhook struct {
+	Token       string `json:"token" schema:"token"`
+	TeamId      string `json:"team_id" schema:"team_id"`
+	TeamName    string `json:"team_domain" schema:"team_domain"`
+	ChannelId   string `json:"channel_id" schema:"channel_id"`
+	ChannelName string `json:"channel_name" schema:"channel_name"`
+	UserId      string `json:"user_id" schema:"user_id"`
+	Username    string `json:"user_name" schema:"user_name"`
+	PostId      string `json:"post_id" schema:"post_id"`
+	Text        string `json:"text" schema:"text"`
+	TriggerWord string `json:"trigger_word" schema:"trigger_word"`
+}
+
+type MMOutgoingWebhookResponse struct {
+	Text         string        `json:"text,omitempty"`
+	ResponseType string        `json:"response_type,omitempty"`
+	Username     string        `json:"username,omitempty"`
+	IconURL      string        `json:"icon_url,omitempty"`
+	Attachments  *[]Attachment `json:"attachments,omitempty"`
+}
+
+func ParseOutgoingWebhook(r *http.Request) (*MMOutgoingWebhook, error) {
+	hook := &MMOutgoingWebhook{}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "application/json" {
+		if err := json.NewDecoder(r.Body).Decode(hook); err != nil {
+			return nil, err
+		}
+		return hook, nil
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return nil, err
+	}
+	decoder := schema.NewDecoder()
+	decoder.IgnoreUnknownKeys(true)
+	if err := decoder.Decode(hook, r.Form); err != nil {
+		return nil, err
+	}
+
+	return hook, nil
+}
+
+// ToSlashCommand converts the webhook to a slash command so it goes through the same permissions and command tree.
+func (hook *MMOutgoingWebhook) ToSlashCommand() *MMSlashCommand {
+	text := strings.TrimSpace(hook.Text)
+	if hook.TriggerWord != "" && strings.HasPrefix(text, hook.TriggerWord) {
+		text = strings.TrimSpace(strings.TrimPrefix(text, hook.TriggerWord))
+	}
+
+	return &MMSlashCommand{
+		ChannelId:   hook.ChannelId,
+		ChannelName: hook.ChannelName,
+		Command:     hook.TriggerWord,
+		TeamName:    hook.TeamName,
+		TeamId:      hook.TeamId,
+		Text:        text,
+		Token:       hook.Token,
+		UserId:      hook.UserId,
+		Username:    hook.Username,
+	}
+}
+
+// toOutgoingWebhookResponse converts a slash response. Outgoing webhooks can't reply ephemerally, so those
+// responses are posted as a reply to the triggering post instead of in the channel. Every response goes
+// through here, so the text and the attachments are redacted here too.
+func toOutgoingWebhookResponse(response *MMSlashResponse) *MMOutgoingWebhookResponse {
+	redactAttachments(response.Attachments)
+
+	outgoing := &MMOutgoingWebhookResponse{
+		Text:        Redact(response.Text),
+		Username:    response.Username,
+		IconURL:     response.IconURL,
+		Attachments: response.Attachments,
+	}
+	if response.ResponseType != IN_CHANNEL {
+		outgoing.ResponseType = OUTGOING_RESPONSE_COMMENT
+	}
+
+	return outgoing
+}
+
+func writeOutgoingWebhookResponse(w http.ResponseWriter, response *MMSlashResponse) {
+	b, err := json.Marshal(toOutgoingWebhookResponse(response))
+	if err != nil {
+		LogError("Unable to marshal outgoing webhook response")
+		b = []byte("{}")
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(b)
+}
+
+func outgoingWebhookHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	hook, err := ParseOutgoingWebhook(r)
+	if err != nil {
+		writeOutgoingWebhookResponse(w, &MMSlashResponse{Text: NewError("Unable to parse incoming outgoing webhook info", err).Error()})
+		return
+	}
+
+	command := hook.ToSlashCommand()
+	handleOutgoingWebhook(w, r, command.WithContext(commandContext(r.Context(), command)))
+}
+
+func handleOutgoingWebhook(w http.ResponseWriter, r *http.Request, command *MMSlashCommand) {
+	response, rejected := runSlashCommand(command, clientIP(r))
+
+	// The reasons a command was rejected were ephemeral, they are only logged instead of being posted.
+	if response == nil || rejected {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	writeOutgoingWebhookResponse(w, response)
+}
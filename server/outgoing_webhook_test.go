@@ -0,0 +1,110 @@
+// Copyright (c) 2018-present Mattermost, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestToSlashCommand(t *testing.T) {
+	for _, tc := range []struct {
+		text        string
+		triggerWord string
+		expected    string
+	}{
+		{text: "matterbuild cut 5.1.0", triggerWord: "matterbuild", expected: "cut 5.1.0"},
+		{text: "  matterbuild   lock status ", triggerWord: "matterbuild", expected: "lock status"},
+		{text: "matterbuild", triggerWord: "matterbuild", expected: ""},
+		{text: "#release cutstatus", triggerWord: "#release", expected: "cutstatus"},
+		{text: "please matterbuild cut", triggerWord: "matterbuild", expected: "please matterbuild cut"},
+		{text: "cut 5.1.0", expected: "cut 5.1.0"},
+	} {
+		hook := &MMOutgoingWebhook{Text: tc.text, TriggerWord: tc.triggerWord, UserId: "user", Username: "someone", ChannelId: "channel", Token: "token"}
+		command := hook.ToSlashCommand()
+		if command.Text != tc.expected {
+			t.Errorf("%q: got %q, expected %q", tc.text, command.Text, tc.expected)
+		}
+		if command.Command != tc.triggerWord || command.UserId != "user" || command.ChannelId != "channel" || command.Token != "token" {
+			t.Errorf("%q: the webhook wasn't copied: %+v", tc.text, command)
+		}
+	}
+}
+
+func TestParseOutgoingWebhook(t *testing.T) {
+	form := url.Values{"token": {"token"}, "user_id": {"user"}, "text": {"matterbuild cutstatus"}, "trigger_word": {"matterbuild"}}
+	r := httptest.NewRequest(http.MethodPost, "/outgoing_webhook", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	hook, err := ParseOutgoingWebhook(r)
+	if err != nil || hook.Token != "token" || hook.UserId != "user" || hook.TriggerWord != "matterbuild" {
+		t.Errorf("unable to parse the form webhook: %+v (err=%v)", hook, err)
+	}
+
+	r = httptest.NewRequest(http.MethodPost, "/outgoing_webhook", strings.NewReader(`{"token": "token", "user_id": "user", "text": "matterbuild cutstatus", "trigger_word": "matterbuild"}`))
+	r.Header.Set("Content-Type", "application/json; charset=utf-8")
+	hook, err = ParseOutgoingWebhook(r)
+	if err != nil || hook.Token != "token" || hook.UserId != "user" || hook.TriggerWord != "matterbuild" {
+		t.Errorf("unable to parse the JSON webhook: %+v (err=%v)", hook, err)
+	}
+}
+
+func TestToOutgoingWebhookResponse(t *testing.T) {
+	attachments := &[]Attachment{{Title: "Release", Text: "Done"}}
+
+	for _, tc := range []struct {
+		responseType string
+		expected     string
+	}{
+		{responseType: IN_CHANNEL, expected: ""},
+		{responseType: EPHEMERAL, expected: OUTGOING_RESPONSE_COMMENT},
+		{responseType: "", expected: OUTGOING_RESPONSE_COMMENT},
+	} {
+		outgoing := toOutgoingWebhookResponse(&MMSlashResponse{ResponseType: tc.responseType, Text: "text", Username: "Matterbuild", Attachments: attachments})
+		if outgoing.ResponseType != tc.expected {
+			t.Errorf("%q: got the response type %q, expected %q", tc.responseType, outgoing.ResponseType, tc.expected)
+		}
+		if outgoing.Text != "text" || outgoing.Username != "Matterbuild" || outgoing.Attachments == nil || (*outgoing.Attachments)[0].Text != "Done" {
+			t.Errorf("%q: the response wasn't copied: %+v", tc.responseType, outgoing)
+		}
+	}
+}
+
+func TestOutgoingWebhookHandler(t *testing.T) {
+	defer setTestConfig(t, &MatterbuildConfig{
+		AllowedTokens: []string{"token"},
+		AllowedUsers:  []string{"user"},
+		UserRateLimit: &RateLimit{RequestsPerMinute: 1, Burst: 1},
+	})()
+
+	post := func(token, userId string) *httptest.ResponseRecorder {
+		body := `{"token": "` + token + `", "user_id": "` + userId + `", "text": "matterbuild lock status", "trigger_word": "matterbuild"}`
+		r := httptest.NewRequest(http.MethodPost, "/outgoing_webhook", strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		outgoingWebhookHandler(w, r, nil)
+		return w
+	}
+
+	// Denied commands aren't answered in the channel.
+	for _, denied := range []struct{ token, userId string }{{"wrong", "user"}, {"token", "stranger"}} {
+		if w := post(denied.token, denied.userId); w.Code != http.StatusOK || w.Body.Len() != 0 {
+			t.Errorf("%+v: expected an empty response, got %v %q", denied, w.Code, w.Body.String())
+		}
+	}
+
+	w := post("token", "user")
+	response := &MMOutgoingWebhookResponse{}
+	if err := json.Unmarshal(w.Body.Bytes(), response); err != nil || response.Attachments == nil {
+		t.Fatalf("expected the response of the command, got %q", w.Body.String())
+	}
+
+	// Neither are the rate limited ones.
+	if w := post("token", "user"); w.Code != http.StatusOK || w.Body.Len() != 0 {
+		t.Errorf("expected an empty response when rate limited, got %v %q", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,266 @@
+// Copyright (c) 2018-present Mattermost, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/bndr/gojenkins"
+	"github.com/mattermost/matterbuild/version"
+)
+
+const (
+	PREFLIGHT_JENKINS     = "jenkins"
+	PREFLIGHT_BRANCH      = "branch"
+	PREFLIGHT_BLOCKERS    = "blockers"
+	PREFLIGHT_CI          = "ci"
+	PREFLIGHT_TRANSLATION = "translation"
+
+	DEFAULT_RELEASE_BLOCKER_LABEL = "release-blocker"
+)
+
+// PreflightCheck is a check run before cutting a release. Job and Parameters are used by the jenkins checks,
+// where {version} and {branch} in the parameters are replaced, and Label by the blockers check.
+type PreflightCheck struct {
+	Type       string
+	Job        string
+	Parameters map[string]string
+	Label      string
+}
+
+// preflightResult is the outcome of a check. A passed check with a warning, like CI that hasn't reported yet,
+// doesn't stop the release but is highlighted in the report.
+type preflightResult struct {
+	Name    string
+	Passed  bool
+	Warning bool
+	Detail  string
+}
+
+const PREFLIGHT_WARNING_ICON = ":warning:"
+
+type preflightRunner func(ctx context.Context, check *PreflightCheck, v *version.Version) *preflightResult
+
+var preflightRunners = map[string]preflightRunner{
+	PREFLIGHT_JENKINS:     runJenkinsPreflight,
+	PREFLIGHT_BRANCH:      runBranchPreflight,
+	PREFLIGHT_BLOCKERS:    runBlockersPreflight,
+	PREFLIGHT_CI:          runCIPreflight,
+	PREFLIGHT_TRANSLATION: runTranslationPreflight,
+}
+
+// preflightChecks returns the PreChecksJob, which always runs, followed by the PreflightChecks of the config.
+func preflightChecks() []*PreflightCheck {
+	checks := []*PreflightCheck{{Type: PREFLIGHT_JENKINS, Job: Cfg().PreChecksJob}}
+	for _, check := range Cfg().PreflightChecks {
+		// The PreChecksJob can be listed to give it parameters.
+		if check.Type == PREFLIGHT_JENKINS && check.Job == Cfg().PreChecksJob {
+			checks[0] = check
+			continue
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+// RunReleasePrechecks runs the pre-flight checks in parallel and returns the report of every check.
+// The error lists the checks that failed.
+func RunReleasePrechecks(ctx context.Context, v *version.Version) (string, *AppError) {
+	checks := preflightChecks()
+	results := make([]*preflightResult, len(checks))
+
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		i, check := i, check
+		go func() {
+			defer wg.Done()
+			results[i] = preflightRunners[check.Type](ctx, check, v)
+		}()
+	}
+	wg.Wait()
+
+	var report []string
+	var failed []string
+	for _, result := range results {
+		icon := stepIcons[STEP_SUCCESS]
+		if result.Warning {
+			icon = PREFLIGHT_WARNING_ICON
+		}
+		if !result.Passed {
+			icon = stepIcons[STEP_FAILED]
+			failed = append(failed, result.Name)
+		}
+		report = append(report, strings.TrimSpace(fmt.Sprintf("%v **%v** %v", icon, result.Name, result.Detail)))
+	}
+
+	msg := strings.Join(report, "\n")
+	if len(failed) > 0 {
+		LogErrorContext(ctx, "[RunReleasePrechecks] Pre-checks failed for "+v.String()+": "+strings.Join(failed, ", "))
+		return msg, NewError("Pre-checks failed!\n"+msg, nil)
+	}
+
+	return msg, nil
+}
+
+func runJenkinsPreflight(ctx context.Context, check *PreflightCheck, v *version.Version) *preflightResult {
+	result := &preflightResult{Name: "Jenkins job " + check.Job}
+
+	parameters := map[string]string{}
+	for key, value := range check.Parameters {
+		value = strings.Replace(value, "{version}", v.String(), -1)
+		parameters[key] = strings.Replace(value, "{branch}", v.ReleaseBranch(), -1)
+	}
+
+	status, err := RunJobWaitForResult(ctx, check.Job, parameters)
+	if err != nil {
+		result.Detail = err.Error()
+	} else if status != gojenkins.STATUS_SUCCESS {
+		result.Detail = "finished with " + status
+		if check.Job == Cfg().PreChecksJob {
+			result.Detail += " (Did you update the database upgrade code?)"
+		}
+	} else {
+		result.Passed = true
+	}
+
+	return result
+}
+
+func runBranchPreflight(ctx context.Context, check *PreflightCheck, v *version.Version) *preflightResult {
+	branch := v.ReleaseBranch()
+	result := &preflightResult{Name: "Branch " + branch}
+
+	if v.IsFirstMinorRelease() {
+		result.Passed = true
+		result.Detail = "will be created by the release job"
+		return result
+	}
+
+	var missing []string
+	for _, repo := range Cfg().Repositories {
+		_, resp, err := githubClient().Repositories.GetBranch(ctx, repo.Owner, repo.Name, branch)
+		if err == nil {
+			continue
+		}
+		if resp == nil || resp.StatusCode != http.StatusNotFound {
+			githubAPIErrorsTotal.Inc(repo.Owner+"/"+repo.Name, "get_branch")
+			result.Detail = "unable to check " + repo.Owner + "/" + repo.Name + ": " + err.Error()
+			return result
+		}
+		missing = append(missing, repo.Owner+"/"+repo.Name)
+	}
+
+	if len(missing) > 0 {
+		result.Detail = "missing in " + strings.Join(missing, ", ")
+	} else {
+		result.Passed = true
+	}
+	return result
+}
+
+func runBlockersPreflight(ctx context.Context, check *PreflightCheck, v *version.Version) *preflightResult {
+	label := check.Label
+	if label == "" {
+		label = DEFAULT_RELEASE_BLOCKER_LABEL
+	}
+	result := &preflightResult{Name: "Open " + label + " PRs"}
+
+	query := fmt.Sprintf("is:pr is:open label:%q", label)
+	for _, repo := range Cfg().Repositories {
+		query += " repo:" + repo.Owner + "/" + repo.Name
+	}
+
+	found, _, err := githubClient().Search.Issues(ctx, query, nil)
+	if err != nil {
+		githubAPIErrorsTotal.Inc("", "search_issues")
+		result.Detail = "unable to search the pull requests: " + err.Error()
+		return result
+	}
+
+	if len(found.Issues) == 0 {
+		result.Passed = true
+		return result
+	}
+
+	var prs []string
+	for _, issue := range found.Issues {
+		prs = append(prs, fmt.Sprintf("[#%v](%v)", issue.GetNumber(), issue.GetHTMLURL()))
+	}
+	result.Detail = strings.Join(prs, ", ")
+	return result
+}
+
+func runCIPreflight(ctx context.Context, check *PreflightCheck, v *version.Version) *preflightResult {
+	// The first release candidate is cut from master, the release branch doesn't exist yet.
+	ref := v.ReleaseBranch()
+	if v.IsFirstMinorRelease() {
+		ref = "master"
+	}
+	result := &preflightResult{Name: "CI on " + ref}
+
+	// GitHub reports a pending state both while the builds run and when none reported yet, neither is a failure.
+	var failing, pending []string
+	for _, repo := range Cfg().Repositories {
+		name := repo.Owner + "/" + repo.Name
+		status, _, err := githubClient().Repositories.GetCombinedStatus(ctx, repo.Owner, repo.Name, ref, nil)
+		if err != nil {
+			githubAPIErrorsTotal.Inc(name, "get_combined_status")
+			result.Detail = "unable to get the status of " + name + ": " + err.Error()
+			return result
+		}
+		switch {
+		case status.GetState() == "success":
+		case status.GetTotalCount() == 0:
+			pending = append(pending, name+" has no statuses yet")
+		case status.GetState() == "pending":
+			pending = append(pending, name+" is pending")
+		default:
+			failing = append(failing, name+" is "+status.GetState())
+		}
+	}
+
+	if len(failing) > 0 {
+		result.Detail = strings.Join(append(failing, pending...), ", ")
+	} else {
+		result.Passed = true
+		result.Warning = len(pending) > 0
+		result.Detail = strings.Join(pending, ", ")
+	}
+	return result
+}
+
+func runTranslationPreflight(ctx context.Context, check *PreflightCheck, v *version.Version) *preflightResult {
+	// The translation server follows master until the release branch is created by the first release candidate.
+	branch := v.ReleaseBranch()
+	if v.IsFirstMinorRelease() {
+		branch = "master"
+	}
+	result := &preflightResult{Name: "Translation server"}
+
+	branches, err := GetTranslationServerBranches(ctx)
+	if err != nil {
+		result.Detail = err.Error()
+		return result
+	}
+
+	var unlocked []string
+	for _, key := range []string{"PLT_BRANCH", "WEB_BRANCH"} {
+		if branches[key] != branch {
+			unlocked = append(unlocked, fmt.Sprintf("%v is %q", key, branches[key]))
+		}
+	}
+
+	if len(unlocked) > 0 {
+		result.Detail = "not locked to " + branch + ": " + strings.Join(unlocked, ", ")
+	} else {
+		result.Passed = true
+		result.Detail = "locked to " + branch
+	}
+	return result
+}
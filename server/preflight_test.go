@@ -0,0 +1,108 @@
+// Copyright (c) 2018-present Mattermost, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mattermost/matterbuild/version"
+)
+
+func TestPreflightChecks(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		configured []*PreflightCheck
+		expected   []string
+	}{
+		{name: "default", expected: []string{"jenkins:prechecks"}},
+		{
+			name:       "prechecks job always runs",
+			configured: []*PreflightCheck{{Type: PREFLIGHT_BRANCH}, {Type: PREFLIGHT_CI}},
+			expected:   []string{"jenkins:prechecks", "branch:", "ci:"},
+		},
+		{
+			name:       "prechecks job with parameters",
+			configured: []*PreflightCheck{{Type: PREFLIGHT_BRANCH}, {Type: PREFLIGHT_JENKINS, Job: "prechecks", Parameters: map[string]string{"BRANCH": "{branch}"}}},
+			expected:   []string{"jenkins:prechecks", "branch:"},
+		},
+		{
+			name:       "other jenkins jobs",
+			configured: []*PreflightCheck{{Type: PREFLIGHT_JENKINS, Job: "lint"}},
+			expected:   []string{"jenkins:prechecks", "jenkins:lint"},
+		},
+	} {
+		restore := setTestConfig(t, &MatterbuildConfig{PreChecksJob: "prechecks", PreflightChecks: tc.configured})
+
+		var checks []string
+		for _, check := range preflightChecks() {
+			checks = append(checks, check.Type+":"+check.Job)
+		}
+		if len(checks) != len(tc.expected) {
+			t.Errorf("%v: got %v, expected %v", tc.name, checks, tc.expected)
+		} else {
+			for i := range checks {
+				if checks[i] != tc.expected[i] {
+					t.Errorf("%v: got %v, expected %v", tc.name, checks, tc.expected)
+					break
+				}
+			}
+		}
+
+		restore()
+	}
+}
+
+func TestTranslationPreflight(t *testing.T) {
+	if testing.Short() {
+		t.Skip("polling waits for the build")
+	}
+
+	// Build 8 finished after the build that was run, its artifact must not be used.
+	jenkins := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch path := strings.TrimSuffix(r.URL.Path, "/"); path {
+		case "/job/check-translation/build":
+			w.Header().Set("Location", "/queue/item/42/")
+			w.WriteHeader(http.StatusCreated)
+		case "/job/check-translation/api/json":
+			fmt.Fprint(w, `{"name": "check-translation", "nextBuildNumber": 7}`)
+		case "/job/check-translation/7/api/json", "/job/check-translation/8/api/json", "/job/check-translation/lastBuild/api/json":
+			number := "8"
+			if strings.Contains(path, "/7/") {
+				number = "7"
+			}
+			fmt.Fprintf(w, `{"number": %v, "building": false, "result": "SUCCESS", "artifacts": [{"fileName": "branches.txt", "relativePath": "branches.txt"}]}`, number)
+		case "/job/check-translation/7/artifact/branches.txt":
+			fmt.Fprint(w, "PLT_BRANCH=\"release-5.1\"\nWEB_BRANCH=\"release-5.1\"\nRN_BRANCH=\"master\"\n")
+		case "/job/check-translation/8/artifact/branches.txt":
+			fmt.Fprint(w, "PLT_BRANCH=\"release-5.2\"\nWEB_BRANCH=\"release-5.2\"\n")
+		default:
+			fmt.Fprint(w, "{}")
+		}
+	}))
+	defer jenkins.Close()
+	defer setTestConfig(t, &MatterbuildConfig{JenkinsURL: jenkins.URL, CheckTranslationServerJob: "check-translation"})()
+
+	for _, tc := range []struct {
+		version string
+		passed  bool
+		detail  string
+	}{
+		{version: "5.1.1", passed: true, detail: "locked to release-5.1"},
+		{version: "5.2.0-rc2", detail: "not locked to release-5.2: PLT_BRANCH is \"release-5.1\", WEB_BRANCH is \"release-5.1\""},
+	} {
+		v, err := version.ParseRelease(tc.version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result := runTranslationPreflight(context.Background(), &PreflightCheck{Type: PREFLIGHT_TRANSLATION}, v)
+		if result.Passed != tc.passed || result.Detail != tc.detail {
+			t.Errorf("%v: got %+v, expected passed=%v %q", tc.version, result, tc.passed, tc.detail)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+// Copyright (c) 2018-present Mattermost, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package server
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+const (
+	STEP_PENDING = "pending"
+	STEP_RUNNING = "running"
+	STEP_SUCCESS = "success"
+	STEP_FAILED  = "failed"
+	STEP_SKIPPED = "skipped"
+)
+
+const (
+	STEP_PRECHECKS   = "Pre-checks"
+	STEP_RELEASE_JOB = "Release job"
+	STEP_RC_TESTING  = "RC testing"
+	STEP_OSS_DEPLOY  = "OSS deploy"
+	STEP_CI_SERVERS  = "CI servers"
+	STEP_PRERELEASE  = "Pre-release"
+)
+
+var stepIcons = map[string]string{
+	STEP_PENDING: ":white_circle:",
+	STEP_RUNNING: ":hourglass_flowing_sand:",
+	STEP_SUCCESS: ":white_check_mark:",
+	STEP_FAILED:  ":x:",
+	STEP_SKIPPED: ":heavy_minus_sign:",
+}
+
+type progressStep struct {
+	Name   string
+	Status string
+	Detail string
+}
+
+// ReleaseProgress keeps a single Mattermost post up to date with the state of a multi step operation
+// and records the details of every step as replies in the post thread.
+// A nil *ReleaseProgress is valid and does nothing, so callers don't need to check if the API is configured.
+type ReleaseProgress struct {
+	sync.Mutex
+	client *MattermostClient
+	title  string
+	post   *MMPost
+	steps  []*progressStep
+
+	// The changes are sent in the background, in order, so a slow Mattermost doesn't hold up the release.
+	updated bool
+	replies []string
+	sending bool
+}
+
+// NewReleaseProgress creates the progress post in the given channel. It returns nil if the Mattermost API
+// is not configured or the post can't be created.
+func NewReleaseProgress(channelId, title string, stepNames []string) *ReleaseProgress {
+	if Cfg().ReleaseChannelId != "" {
+		channelId = Cfg().ReleaseChannelId
+	}
+
+	client := NewMattermostClient()
+	if client == nil || channelId == "" {
+		return nil
+	}
+
+	progress := &ReleaseProgress{
+		client: client,
+		title:  title,
+	}
+	for _, name := range stepNames {
+		progress.steps = append(progress.steps, &progressStep{Name: name, Status: STEP_PENDING})
+	}
+
+	post, err := client.CreatePost(&MMPost{
+		ChannelId: channelId,
+		Props:     PostAttachmentProps(title, progress.render(), progress.color()),
+	})
+	if err != nil {
+		LogError("[NewReleaseProgress] Unable to create the progress post err=" + err.Error())
+		return nil
+	}
+	progress.post = post
+
+	return progress
+}
+
+func (p *ReleaseProgress) render() string {
+	msg := ""
+	for _, step := range p.steps {
+		msg += fmt.Sprintf("%v **%v**", stepIcons[step.Status], step.Name)
+		if step.Detail != "" {
+			msg += " - " + step.Detail
+		}
+		msg += "\n"
+	}
+	return msg
+}
+
+func (p *ReleaseProgress) color() string {
+	finished := true
+	for _, step := range p.steps {
+		if step.Status == STEP_FAILED {
+			return "#e20025"
+		}
+		if step.Status == STEP_PENDING || step.Status == STEP_RUNNING {
+			finished = false
+		}
+	}
+
+	if finished {
+		return "#86c323"
+	}
+	return "#0060aa"
+}
+
+func (p *ReleaseProgress) setStep(name, status, detail string) {
+	if p == nil {
+		return
+	}
+
+	p.Lock()
+	defer p.Unlock()
+
+	var step *progressStep
+	for _, s := range p.steps {
+		if s.Name == name {
+			step = s
+			break
+		}
+	}
+	if step == nil {
+		step = &progressStep{Name: name}
+		p.steps = append(p.steps, step)
+	}
+	step.Status = status
+	step.Detail = detail
+
+	reply := ""
+	if status != STEP_PENDING {
+		reply = fmt.Sprintf("%v **%v**: %v", stepIcons[status], name, status)
+		if detail != "" {
+			reply += "\n" + detail
+		}
+	}
+	p.queue(reply)
+}
+
+func (p *ReleaseProgress) StepRunning(name, detail string) {
+	p.setStep(name, STEP_RUNNING, detail)
+}
+
+func (p *ReleaseProgress) StepSucceeded(name, detail string) {
+	p.setStep(name, STEP_SUCCESS, detail)
+}
+
+func (p *ReleaseProgress) StepFailed(name, detail string) {
+	p.setStep(name, STEP_FAILED, detail)
+}
+
+func (p *ReleaseProgress) StepSkipped(name, detail string) {
+	p.setStep(name, STEP_SKIPPED, detail)
+}
+
+// SkipRemaining marks the steps that haven't started as skipped, when the operation stops before reaching them.
+func (p *ReleaseProgress) SkipRemaining(detail string) {
+	if p == nil {
+		return
+	}
+
+	p.Lock()
+	defer p.Unlock()
+
+	var skipped []string
+	for _, step := range p.steps {
+		if step.Status == STEP_PENDING {
+			step.Status = STEP_SKIPPED
+			step.Detail = detail
+			skipped = append(skipped, step.Name)
+		}
+	}
+	if len(skipped) == 0 {
+		return
+	}
+
+	reply := fmt.Sprintf("%v **%v**: %v", stepIcons[STEP_SKIPPED], strings.Join(skipped, "**, **"), STEP_SKIPPED)
+	if detail != "" {
+		reply += "\n" + detail
+	}
+	p.queue(reply)
+}
+
+// queue marks the post as updated, adds the reply if there is one and starts sending them. It must be called
+// with the lock held.
+func (p *ReleaseProgress) queue(reply string) {
+	p.updated = true
+	if reply != "" {
+		p.replies = append(p.replies, reply)
+	}
+
+	if !p.sending {
+		p.sending = true
+		go p.send()
+	}
+}
+
+// send updates the post and adds the queued replies until there is nothing left to send.
+func (p *ReleaseProgress) send() {
+	for {
+		p.Lock()
+		if !p.updated && len(p.replies) == 0 {
+			p.sending = false
+			p.Unlock()
+			return
+		}
+
+		var update *MMPost
+		if p.updated {
+			update = &MMPost{Id: p.post.Id, ChannelId: p.post.ChannelId, Props: PostAttachmentProps(p.title, p.render(), p.color())}
+			p.updated = false
+		}
+		replies := p.replies
+		p.replies = nil
+		p.Unlock()
+
+		if update != nil {
+			if _, err := p.client.UpdatePost(update); err != nil {
+				LogError("[ReleaseProgress] Unable to update the progress of " + p.title + " err=" + err.Error())
+			}
+		}
+		for _, reply := range replies {
+			if _, err := p.client.ReplyToPost(p.post, reply); err != nil {
+				LogError("[ReleaseProgress] Unable to reply to the progress of " + p.title + " err=" + err.Error())
+			}
+		}
+	}
+}
+
+// StepResult marks the step as succeeded or failed depending on err.
+func (p *ReleaseProgress) StepResult(name string, err *AppError) {
+	if err != nil {
+		p.StepFailed(name, err.Error())
+	} else {
+		p.StepSucceeded(name, "")
+	}
+}
@@ -0,0 +1,169 @@
+// Copyright (c) 2018-present Mattermost, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package server
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Commands that start background operations or Jenkins jobs and count against MaxConcurrentOperationsPerUser.
+var operationCommands = []string{"cut", "runjob", "loadtest", "lockpootle", "getpootle"}
+
+// tokenBucket refills RequestsPerMinute tokens per minute up to Burst, each command takes one.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+	// fullAt is when the bucket is back to Burst tokens, from then on it is the same as a new bucket.
+	fullAt time.Time
+}
+
+// RATE_LIMIT_SWEEP_INTERVAL is how often the buckets that refilled are dropped, so the buckets of the users
+// and commands that stopped sending commands don't pile up.
+const RATE_LIMIT_SWEEP_INTERVAL = 10 * time.Minute
+
+var rateLimitBuckets = map[string]*tokenBucket{}
+var rateLimitSweptAt time.Time
+var rateLimitBucketsLock sync.Mutex
+
+// operationLimitLock makes checking the running operations and registering the new one a single step.
+var operationLimitLock sync.Mutex
+
+// bucketLimit is the bucket for key, limited by limit.
+type bucketLimit struct {
+	key   string
+	limit *RateLimit
+}
+
+// takeTokens takes a token from every bucket, or from none of them when one of them is empty, so a command
+// rejected by one limit doesn't count against the others. When a bucket is empty it returns its index and how
+// long until its next token.
+func takeTokens(now time.Time, buckets ...bucketLimit) (bool, int, time.Duration) {
+	rateLimitBucketsLock.Lock()
+	defer rateLimitBucketsLock.Unlock()
+
+	if now.Sub(rateLimitSweptAt) >= RATE_LIMIT_SWEEP_INTERVAL {
+		for key, bucket := range rateLimitBuckets {
+			if !now.Before(bucket.fullAt) {
+				delete(rateLimitBuckets, key)
+			}
+		}
+		rateLimitSweptAt = now
+	}
+
+	var refilled []*tokenBucket
+	var perSeconds []float64
+	for i, b := range buckets {
+		if b.limit == nil || b.limit.RequestsPerMinute <= 0 {
+			refilled = append(refilled, nil)
+			perSeconds = append(perSeconds, 0)
+			continue
+		}
+
+		burst := float64(b.limit.Burst)
+		if burst < 1 {
+			burst = 1
+		}
+		perSecond := float64(b.limit.RequestsPerMinute) / 60
+
+		bucket, ok := rateLimitBuckets[b.key]
+		if !ok {
+			bucket = &tokenBucket{tokens: burst, last: now}
+			rateLimitBuckets[b.key] = bucket
+		}
+
+		bucket.tokens = math.Min(burst, bucket.tokens+now.Sub(bucket.last).Seconds()*perSecond)
+		bucket.last = now
+
+		bucket.fullAt = now.Add(secondsToDuration((burst - bucket.tokens) / perSecond))
+
+		if bucket.tokens < 1 {
+			return false, i, secondsToDuration((1 - bucket.tokens) / perSecond)
+		}
+		refilled = append(refilled, bucket)
+		perSeconds = append(perSeconds, perSecond)
+	}
+
+	for i, bucket := range refilled {
+		if bucket != nil {
+			bucket.tokens--
+			bucket.fullAt = bucket.fullAt.Add(secondsToDuration(1 / perSeconds[i]))
+		}
+	}
+	return true, -1, 0
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
+
+func formatWait(wait time.Duration) string {
+	return fmt.Sprintf("%vs", int(math.Ceil(wait.Seconds())))
+}
+
+// runningOperations returns the name of the operations of the user, one per command that started them.
+func runningOperations(userId string) []string {
+	var running []string
+	seen := map[string]bool{}
+	for _, op := range InFlightOperations() {
+		if op.UserId != userId {
+			continue
+		}
+		if op.RequestId != "" {
+			if seen[op.RequestId] {
+				continue
+			}
+			seen[op.RequestId] = true
+		}
+		running = append(running, op.Name)
+	}
+	return running
+}
+
+// checkRateLimits applies the cap on concurrent operations per user and the per user, per user and command
+// and per command rate limits. The tokens are only taken when the command is allowed. The allowed commands
+// that start operations are registered as an Operation until they return, so the commands that only queue a
+// Jenkins job count against the cap too. The caller must Finish it, it is nil for the other commands.
+func checkRateLimits(command *MMSlashCommand) (*Operation, *AppError) {
+	name := commandName(command)
+	if name == "" || name == "unknown" {
+		return nil, nil
+	}
+
+	isOperation := containsOrEmpty(operationCommands, name)
+	if isOperation {
+		operationLimitLock.Lock()
+		defer operationLimitLock.Unlock()
+	}
+
+	if max := Cfg().MaxConcurrentOperationsPerUser; max > 0 && isOperation {
+		if running := runningOperations(command.UserId); len(running) >= max {
+			return nil, NewError(fmt.Sprintf("You already have %v operation(s) running (%v). Please wait for them to finish before starting `%v`.", len(running), strings.Join(running, ", "), name), nil)
+		}
+	}
+
+	commandLimit := Cfg().CommandRateLimits[name]
+	globalLimit := Cfg().GlobalCommandRateLimits[name]
+	ok, empty, wait := takeTokens(time.Now(),
+		bucketLimit{"user:" + command.UserId, Cfg().UserRateLimit},
+		bucketLimit{"command:" + name + ":" + command.UserId, commandLimit},
+		bucketLimit{"command:" + name, globalLimit},
+	)
+	switch {
+	case !ok && empty == 0:
+		return nil, NewError(fmt.Sprintf("Easy there! You are limited to %v commands per minute, please try again in %v.", Cfg().UserRateLimit.RequestsPerMinute, formatWait(wait)), nil)
+	case !ok && empty == 1:
+		return nil, NewError(fmt.Sprintf("Easy there! `%v` is limited to %v per minute, please try again in %v.", name, commandLimit.RequestsPerMinute, formatWait(wait)), nil)
+	case !ok:
+		return nil, NewError(fmt.Sprintf("Easy there! `%v` is limited to %v per minute for everyone, please try again in %v.", name, globalLimit.RequestsPerMinute, formatWait(wait)), nil)
+	}
+
+	if !isOperation {
+		return nil, nil
+	}
+	return StartOperation(name, command.UserId, LogFieldsFrom(command.Context())["request_id"], map[string]string{"command": command.Text}), nil
+}
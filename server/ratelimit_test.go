@@ -0,0 +1,175 @@
+// Copyright (c) 2018-present Mattermost, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package server
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func resetRateLimitBuckets() {
+	rateLimitBucketsLock.Lock()
+	rateLimitBuckets = map[string]*tokenBucket{}
+	rateLimitSweptAt = time.Time{}
+	rateLimitBucketsLock.Unlock()
+}
+
+func TestTakeTokens(t *testing.T) {
+	start := time.Date(2018, 6, 4, 12, 0, 0, 0, time.UTC)
+	userLimit := &RateLimit{RequestsPerMinute: 6, Burst: 2}
+	commandLimit := &RateLimit{RequestsPerMinute: 1, Burst: 1}
+
+	for _, tc := range []struct {
+		name  string
+		takes []time.Duration
+		// The result of the last take
+		ok    bool
+		empty int
+		wait  time.Duration
+	}{
+		{name: "first take", takes: []time.Duration{0}, ok: true, empty: -1},
+		{name: "burst", takes: []time.Duration{0, 0, 0}, ok: false, empty: 0, wait: 10 * time.Second},
+		{name: "refilled", takes: []time.Duration{0, 0, 10 * time.Second}, ok: true, empty: -1},
+		{name: "partially refilled", takes: []time.Duration{0, 0, 4 * time.Second}, ok: false, empty: 0, wait: 6 * time.Second},
+	} {
+		resetRateLimitBuckets()
+
+		var ok bool
+		var empty int
+		var wait time.Duration
+		for _, at := range tc.takes {
+			ok, empty, wait = takeTokens(start.Add(at), bucketLimit{"user", userLimit})
+		}
+		if ok != tc.ok || empty != tc.empty || wait.Round(time.Second) != tc.wait {
+			t.Errorf("%v: got (%v, %v, %v), expected (%v, %v, %v)", tc.name, ok, empty, wait, tc.ok, tc.empty, tc.wait)
+		}
+	}
+
+	// A command rejected by its own limit doesn't take a token from the user bucket.
+	resetRateLimitBuckets()
+	buckets := []bucketLimit{{"user", userLimit}, {"command", commandLimit}}
+	if ok, _, _ := takeTokens(start, buckets...); !ok {
+		t.Fatal("expected the first command to be allowed")
+	}
+	if ok, empty, _ := takeTokens(start, buckets...); ok || empty != 1 {
+		t.Fatalf("expected the command limit to reject the second command, got (%v, %v)", ok, empty)
+	}
+	if ok, _, _ := takeTokens(start, bucketLimit{"user", userLimit}); !ok {
+		t.Error("the rejected command took a token from the user bucket")
+	}
+
+	// Buckets without a limit never reject.
+	resetRateLimitBuckets()
+	for i := 0; i < 10; i++ {
+		if ok, _, _ := takeTokens(start, bucketLimit{"none", nil}, bucketLimit{"zero", &RateLimit{}}); !ok {
+			t.Fatal("expected buckets without a limit to allow every command")
+		}
+	}
+}
+
+func TestCheckRateLimitsOperations(t *testing.T) {
+	defer setTestConfig(t, &MatterbuildConfig{MaxConcurrentOperationsPerUser: 1})()
+	resetRateLimitBuckets()
+
+	command := &MMSlashCommand{Command: "/matterbuild", UserId: "releaser", Text: "runjob some-job"}
+
+	// The release and the Jenkins builds it waits for are a single command.
+	release := StartOperation("Release 5.0.0", "releaser", "request-1", nil)
+	job := StartOperation("Job release", "releaser", "request-1", nil)
+	other := StartOperation("Job release", "someone", "request-2", nil)
+
+	if running := runningOperations("releaser"); len(running) != 1 {
+		t.Errorf("expected one running operation, got %v", running)
+	}
+	if _, err := checkRateLimits(command); err == nil {
+		t.Error("expected runjob to be rejected while the release is running")
+	}
+	if op, err := checkRateLimits(&MMSlashCommand{Command: "/matterbuild", UserId: "releaser", Text: "cutstatus"}); err != nil || op != nil {
+		t.Errorf("expected commands without operations to be allowed without an operation, got %+v (err=%v)", op, err)
+	}
+
+	job.Finish()
+	release.Finish()
+	other.Finish()
+
+	// runjob only queues the build, it counts as an operation while it runs.
+	op, err := checkRateLimits(command)
+	if err != nil || op == nil {
+		t.Fatalf("expected runjob to be allowed once the release is done, got %+v (err=%v)", op, err)
+	}
+	if _, err := checkRateLimits(&MMSlashCommand{Command: "/matterbuild", UserId: "releaser", Text: "loadtest pr-1234"}); err == nil {
+		t.Error("expected loadtest to be rejected while runjob is running")
+	}
+	op.Finish()
+	if op, err := checkRateLimits(&MMSlashCommand{Command: "/matterbuild", UserId: "releaser", Text: "loadtest pr-1234"}); err != nil {
+		t.Errorf("expected loadtest to be allowed once runjob returned, got %v", err)
+	} else {
+		op.Finish()
+	}
+}
+
+func TestCheckRateLimitsGlobalCommandLimit(t *testing.T) {
+	defer setTestConfig(t, &MatterbuildConfig{
+		CommandRateLimits:       map[string]*RateLimit{"runjob": {RequestsPerMinute: 2, Burst: 2}},
+		GlobalCommandRateLimits: map[string]*RateLimit{"runjob": {RequestsPerMinute: 1, Burst: 1}},
+	})()
+	resetRateLimitBuckets()
+
+	run := func(userId, text string) *AppError {
+		op, err := checkRateLimits(&MMSlashCommand{Command: "/matterbuild", UserId: userId, Text: text})
+		if op != nil {
+			op.Finish()
+		}
+		return err
+	}
+
+	if err := run("user1", "runjob some-job"); err != nil {
+		t.Fatal(err)
+	}
+	if err := run("user2", "runjob some-job"); err == nil || !strings.Contains(err.Error(), "for everyone") {
+		t.Errorf("expected the other user to hit the global limit, got %v", err)
+	}
+	if err := run("user2", "lock status"); err != nil {
+		t.Errorf("expected the other commands to be allowed, got %v", err)
+	}
+}
+
+func TestTakeTokensDropsIdleBuckets(t *testing.T) {
+	resetRateLimitBuckets()
+	start := time.Date(2018, 6, 4, 12, 0, 0, 0, time.UTC)
+	limit := &RateLimit{RequestsPerMinute: 6, Burst: 2}
+	count := func() int {
+		rateLimitBucketsLock.Lock()
+		defer rateLimitBucketsLock.Unlock()
+		return len(rateLimitBuckets)
+	}
+
+	takeTokens(start, bucketLimit{"idle", limit})
+	takeTokens(start, bucketLimit{"busy", limit}, bucketLimit{"busy2", limit})
+	takeTokens(start, bucketLimit{"busy", limit})
+	if count() != 3 {
+		t.Fatalf("expected 3 buckets, got %v", count())
+	}
+
+	// The idle bucket is full again after 10s, the busy one is still refilling when the buckets are swept.
+	takeTokens(start.Add(RATE_LIMIT_SWEEP_INTERVAL-time.Second), bucketLimit{"busy", limit})
+	takeTokens(start.Add(RATE_LIMIT_SWEEP_INTERVAL-time.Second), bucketLimit{"busy", limit})
+	takeTokens(start.Add(RATE_LIMIT_SWEEP_INTERVAL), bucketLimit{"other", nil})
+
+	rateLimitBucketsLock.Lock()
+	_, idle := rateLimitBuckets["idle"]
+	_, busy := rateLimitBuckets["busy"]
+	rateLimitBucketsLock.Unlock()
+	if idle || !busy {
+		t.Errorf("expected only the full buckets to be dropped, got idle=%v busy=%v", idle, busy)
+	}
+
+	// A dropped bucket starts over full.
+	for i := 0; i < 2; i++ {
+		if ok, _, _ := takeTokens(start.Add(RATE_LIMIT_SWEEP_INTERVAL), bucketLimit{"idle", limit}); !ok {
+			t.Fatal("expected the dropped bucket to start full")
+		}
+	}
+}
@@ -0,0 +1,267 @@
+// Copyright (c) 2018-present Mattermost, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	RELEASES_FILE = "releases.json"
+
+	RELEASE_RUNNING     = "running"
+	RELEASE_SUCCEEDED   = "success"
+	RELEASE_FAILED      = "failed"
+	RELEASE_INTERRUPTED = "interrupted"
+)
+
+// ReleaseBuild is a Jenkins build started as part of a release.
+type ReleaseBuild struct {
+	Step   string
+	Job    string
+	Number int64
+	Result string
+}
+
+// ReleaseRecord is the history of a cut, from the pre-checks to the last chained job.
+type ReleaseRecord struct {
+	Id        string
+	Version   string
+	RC        string
+	Backport  bool
+	DryRun    bool
+	UserId    string
+	Username  string
+	StartedAt time.Time
+	EndedAt   time.Time
+	Outcome   string
+	Detail    string
+	Builds    []*ReleaseBuild
+}
+
+// updateReleaseHistory loads the history, lets update change it and saves it if update reports a change.
+func updateReleaseHistory(update func(records []*ReleaseRecord) ([]*ReleaseRecord, bool)) *AppError {
+	var records []*ReleaseRecord
+	return updateJSON(RELEASES_FILE, &records, func() (bool, *AppError) {
+		var changed bool
+		records, changed = update(records)
+		return changed, nil
+	})
+}
+
+// StartReleaseRecord adds a running release to the history.
+func StartReleaseRecord(version, rc string, backport, dryRun bool, userId, username string) *ReleaseRecord {
+	record := &ReleaseRecord{
+		Id:        NewRequestId(),
+		Version:   version,
+		RC:        rc,
+		Backport:  backport,
+		DryRun:    dryRun,
+		UserId:    userId,
+		Username:  username,
+		StartedAt: time.Now(),
+		Outcome:   RELEASE_RUNNING,
+	}
+
+	err := updateReleaseHistory(func(records []*ReleaseRecord) ([]*ReleaseRecord, bool) {
+		return append(records, record), true
+	})
+	if err != nil {
+		LogError("[StartReleaseRecord] Unable to save the release history err=" + err.Error())
+	}
+
+	return record
+}
+
+// update applies change to the record and to its stored copy.
+func (r *ReleaseRecord) update(change func(record *ReleaseRecord)) {
+	change(r)
+
+	err := updateReleaseHistory(func(records []*ReleaseRecord) ([]*ReleaseRecord, bool) {
+		for _, stored := range records {
+			if stored.Id == r.Id {
+				change(stored)
+				return records, true
+			}
+		}
+		return records, false
+	})
+	if err != nil {
+		LogError("[ReleaseRecord.update] Unable to save the release history err=" + err.Error())
+	}
+}
+
+// AddBuild records a Jenkins build of the release. A nil *ReleaseRecord does nothing.
+func (r *ReleaseRecord) AddBuild(step, job string, number int64, result string) {
+	if r == nil {
+		return
+	}
+
+	r.update(func(record *ReleaseRecord) {
+		record.Builds = append(record.Builds, &ReleaseBuild{Step: step, Job: job, Number: number, Result: result})
+	})
+}
+
+// Finish records the outcome of the release. A nil *ReleaseRecord does nothing.
+func (r *ReleaseRecord) Finish(outcome, detail string) {
+	if r == nil {
+		return
+	}
+
+	endedAt := time.Now()
+	r.update(func(record *ReleaseRecord) {
+		record.Outcome = outcome
+		record.Detail = detail
+		record.EndedAt = endedAt
+	})
+}
+
+// markInterruptedReleases marks the releases that were still running when matterbuild stopped.
+func markInterruptedReleases() {
+	err := updateReleaseHistory(func(records []*ReleaseRecord) ([]*ReleaseRecord, bool) {
+		changed := false
+		for _, record := range records {
+			if record.Outcome == RELEASE_RUNNING {
+				record.Outcome = RELEASE_INTERRUPTED
+				record.Detail = "matterbuild stopped before the release finished"
+				changed = true
+			}
+		}
+		return records, changed
+	})
+	if err != nil {
+		LogError("[markInterruptedReleases] Unable to update the release history err=" + err.Error())
+	}
+}
+
+// FindReleaseRecords returns the releases started after since whose version starts with versionPrefix,
+// the most recent first.
+func FindReleaseRecords(since time.Time, versionPrefix string) ([]*ReleaseRecord, *AppError) {
+	var records []*ReleaseRecord
+	if _, err := loadJSON(RELEASES_FILE, &records); err != nil {
+		return nil, err
+	}
+
+	var found []*ReleaseRecord
+	for _, record := range records {
+		if record.StartedAt.Before(since) || !strings.HasPrefix(record.Version, versionPrefix) {
+			continue
+		}
+		found = append(found, record)
+	}
+
+	sort.SliceStable(found, func(i, j int) bool {
+		return found[i].StartedAt.After(found[j].StartedAt)
+	})
+
+	return found, nil
+}
+
+// parseSince parses the --since flag of the releases command, a date like 2018-06-01 or a number of days like 30d.
+func parseSince(since string) (time.Time, *AppError) {
+	if since == "" {
+		return time.Time{}, nil
+	}
+
+	if strings.HasSuffix(since, "d") {
+		var days int
+		if _, err := fmt.Sscanf(since, "%dd", &days); err == nil && days >= 0 {
+			return time.Now().AddDate(0, 0, -days), nil
+		}
+	}
+
+	t, err := time.ParseInLocation("2006-01-02", since, time.Local)
+	if err != nil {
+		return time.Time{}, NewError("Bad --since argument, use a date like 2018-06-01 or a number of days like 30d.", err)
+	}
+	return t, nil
+}
+
+func releaseFlags(record *ReleaseRecord) string {
+	var flags []string
+	if record.Backport {
+		flags = append(flags, "backport")
+	}
+	if record.DryRun {
+		flags = append(flags, "dryrun")
+	}
+	return strings.Join(flags, ", ")
+}
+
+func releaseDuration(record *ReleaseRecord) string {
+	if record.EndedAt.IsZero() {
+		return ""
+	}
+	return record.EndedAt.Sub(record.StartedAt).Round(time.Second).String()
+}
+
+func listReleasesCmdF(args []string, w http.ResponseWriter, slashCommand *MMSlashCommand, since string, versionPrefix string) error {
+	sinceTime, err := parseSince(since)
+	if err != nil {
+		WriteErrorResponse(w, err)
+		return nil
+	}
+
+	records, err := FindReleaseRecords(sinceTime, versionPrefix)
+	if err != nil {
+		return err
+	}
+
+	if len(records) == 0 {
+		WriteEnrichedResponse(w, "Releases", "No releases found.", "#0060aa", EPHEMERAL)
+		return nil
+	}
+
+	msg := "| Version | Started | By | Flags | Outcome | Duration |\n|---|---|---|---|---|---|\n"
+	for _, record := range records {
+		msg += fmt.Sprintf("| %v | %v | @%v | %v | %v | %v |\n", record.Version, record.StartedAt.Format("2006-01-02 15:04 MST"), record.Username, releaseFlags(record), record.Outcome, releaseDuration(record))
+	}
+
+	WriteEnrichedResponse(w, "Releases", msg, "#0060aa", EPHEMERAL)
+	return nil
+}
+
+func showReleaseCmdF(args []string, w http.ResponseWriter, slashCommand *MMSlashCommand) error {
+	if len(args) < 1 {
+		return NewError("You need to specify a release version.", nil)
+	}
+
+	records, err := FindReleaseRecords(time.Time{}, args[0])
+	if err != nil {
+		return err
+	}
+
+	var msg string
+	for _, record := range records {
+		if record.Version != args[0] {
+			continue
+		}
+
+		msg += fmt.Sprintf("#### %v\n", record.Version)
+		msg += fmt.Sprintf("* Cut by: @%v\n", record.Username)
+		msg += fmt.Sprintf("* Started: %v\n", record.StartedAt.Format("2006-01-02 15:04:05 MST"))
+		if !record.EndedAt.IsZero() {
+			msg += fmt.Sprintf("* Ended: %v (%v)\n", record.EndedAt.Format("2006-01-02 15:04:05 MST"), releaseDuration(record))
+		}
+		if flags := releaseFlags(record); flags != "" {
+			msg += fmt.Sprintf("* Flags: %v\n", flags)
+		}
+		msg += fmt.Sprintf("* Outcome: **%v** %v\n", record.Outcome, record.Detail)
+		for _, build := range record.Builds {
+			msg += fmt.Sprintf("* %v: %v #%v %v\n", build.Step, build.Job, build.Number, build.Result)
+		}
+	}
+
+	if msg == "" {
+		WriteEnrichedResponse(w, "Release "+args[0], "No release found for this version.", "#0060aa", EPHEMERAL)
+		return nil
+	}
+
+	WriteEnrichedResponse(w, "Release "+args[0], msg, "#0060aa", EPHEMERAL)
+	return nil
+}
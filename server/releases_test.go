@@ -0,0 +1,59 @@
+// Copyright (c) 2018-present Mattermost, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReleaseHistoryIsShared(t *testing.T) {
+	defer setTestConfig(t, &MatterbuildConfig{})()
+
+	record := StartReleaseRecord("5.1.0-rc1", "rc1", false, false, "user", "user")
+
+	// Another matterbuild process records a release in the meantime.
+	other := &ReleaseRecord{Id: "other", Version: "5.0.2", StartedAt: time.Now(), Outcome: RELEASE_RUNNING}
+	if err := saveJSON(RELEASES_FILE, []*ReleaseRecord{mustFindRelease(t, record.Id), other}); err != nil {
+		t.Fatal(err)
+	}
+
+	record.AddBuild(STEP_RELEASE_JOB, "release", 12, "SUCCESS")
+	record.Finish(RELEASE_SUCCEEDED, "")
+
+	records, err := FindReleaseRecords(time.Time{}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("expected the two releases, got %v", len(records))
+	}
+
+	stored := mustFindRelease(t, record.Id)
+	if stored.Outcome != RELEASE_SUCCEEDED || len(stored.Builds) != 1 || stored.EndedAt.IsZero() {
+		t.Errorf("the release wasn't updated: %+v", stored)
+	}
+	if mustFindRelease(t, "other").Outcome != RELEASE_RUNNING {
+		t.Error("the release of the other process was changed")
+	}
+
+	markInterruptedReleases()
+	if outcome := mustFindRelease(t, "other").Outcome; outcome != RELEASE_INTERRUPTED {
+		t.Errorf("expected the running release to be interrupted, got %v", outcome)
+	}
+}
+
+func mustFindRelease(t *testing.T, id string) *ReleaseRecord {
+	records, err := FindReleaseRecords(time.Time{}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, record := range records {
+		if record.Id == id {
+			return record
+		}
+	}
+	t.Fatalf("release %v not found", id)
+	return nil
+}
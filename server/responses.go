@@ -4,7 +4,9 @@
 package server
 
 import (
+	"bytes"
 	"encoding/json"
+	"net/http"
 )
 
 type MMSlashResponse struct {
@@ -44,7 +46,7 @@ type AttachmentField struct {
 func GenerateStandardSlashResponse(text string, respType string) string {
 	response := MMSlashResponse{
 		ResponseType: respType,
-		Text:         text,
+		Text:         Redact(text),
 		GotoLocation: "",
 		Username:     "Matterbuild",
 		IconURL:      "https://www.mattermost.org/wp-content/uploads/2016/04/icon.png",
@@ -59,6 +61,9 @@ func GenerateStandardSlashResponse(text string, respType string) string {
 }
 
 func GenerateEnrichedSlashResponse(title, text, color, respType string) string {
+	title = Redact(title)
+	text = Redact(text)
+
 	msgAttachment := &[]Attachment{{
 		Fallback:   text,
 		Color:      color,
@@ -85,3 +90,38 @@ func GenerateEnrichedSlashResponse(title, text, color, respType string) string {
 
 	return string(b)
 }
+
+// bufferedResponseWriter captures a slash response so it can be converted to another format.
+type bufferedResponseWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newBufferedResponseWriter() *bufferedResponseWriter {
+	return &bufferedResponseWriter{
+		header: http.Header{},
+		status: http.StatusOK,
+	}
+}
+
+func (w *bufferedResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *bufferedResponseWriter) Write(b []byte) (int, error) {
+	return w.body.Write(b)
+}
+
+func (w *bufferedResponseWriter) WriteHeader(status int) {
+	w.status = status
+}
+
+// SlashResponse decodes the first slash response written, or nil if nothing was written.
+func (w *bufferedResponseWriter) SlashResponse() *MMSlashResponse {
+	response := &MMSlashResponse{}
+	if err := json.NewDecoder(bytes.NewReader(w.body.Bytes())).Decode(response); err != nil {
+		return nil
+	}
+	return response
+}
@@ -0,0 +1,287 @@
+// Copyright (c) 2018-present Mattermost, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bndr/gojenkins"
+)
+
+const SCHEDULES_FILE = "schedules.json"
+
+// ScheduledJob is a Jenkins job run by the scheduler every time its Cron expression matches.
+// The result is posted to the channel it was scheduled from.
+type ScheduledJob struct {
+	Id         string
+	Cron       string
+	Job        string
+	Parameters map[string]string
+	ChannelId  string
+	UserId     string
+	Username   string
+	CreatedAt  time.Time
+	LastRunAt  time.Time
+	LastResult string
+}
+
+// loadSchedules returns the saved schedules.
+func loadSchedules() ([]*ScheduledJob, *AppError) {
+	var schedules []*ScheduledJob
+	if _, err := loadJSON(SCHEDULES_FILE, &schedules); err != nil {
+		return nil, err
+	}
+	return schedules, nil
+}
+
+// updateSchedules loads the schedules, lets update change them and saves them if it reports a change.
+func updateSchedules(update func(schedules []*ScheduledJob) ([]*ScheduledJob, bool, *AppError)) *AppError {
+	var schedules []*ScheduledJob
+	return updateJSON(SCHEDULES_FILE, &schedules, func() (bool, *AppError) {
+		updated, changed, err := update(schedules)
+		schedules = updated
+		return changed, err
+	})
+}
+
+// runScheduler checks the schedules and the calendar reminders at the start of every minute until
+// matterbuild shuts down.
+func runScheduler() {
+	LogInfo("[runScheduler] Starting the scheduler")
+	for !IsShuttingDown() {
+		now := time.Now()
+		time.Sleep(now.Truncate(time.Minute).Add(time.Minute).Sub(now))
+		if IsShuttingDown() {
+			break
+		}
+		runDueSchedules(time.Now().Truncate(time.Minute))
+		sendCalendarReminders(time.Now())
+	}
+}
+
+// runDueSchedules runs the schedules that were due since their last run, so a run missed while matterbuild
+// was down or busy is caught up once.
+func runDueSchedules(minute time.Time) {
+	var due []*ScheduledJob
+	err := updateSchedules(func(schedules []*ScheduledJob) ([]*ScheduledJob, bool, *AppError) {
+		for _, schedule := range schedules {
+			cron, err := ParseCron(schedule.Cron)
+			if err != nil {
+				LogError("[runDueSchedules] Invalid cron for schedule " + schedule.Id + " err=" + err.Error())
+				continue
+			}
+
+			since := schedule.LastRunAt
+			if since.IsZero() {
+				since = schedule.CreatedAt
+			}
+			if since.IsZero() {
+				since = minute.Add(-time.Minute)
+			}
+			if next := cron.Next(since); !next.IsZero() && !next.After(minute) {
+				if next.Before(minute) {
+					LogInfo("[runDueSchedules] Catching up the run of schedule " + schedule.Id + " missed at " + next.Format(time.RFC3339))
+				}
+				schedule.LastRunAt = minute
+				due = append(due, schedule)
+			}
+		}
+		return schedules, len(due) > 0, nil
+	})
+	if err != nil {
+		LogError("[runDueSchedules] Unable to update the schedules err=" + err.Error())
+		return
+	}
+
+	for _, schedule := range due {
+		ctx := WithLogFields(context.Background(), LogFields{"request_id": NewRequestId(), "user_id": schedule.UserId, "schedule_id": schedule.Id})
+		go runScheduledJob(ctx, schedule)
+	}
+}
+
+// runScheduledJob runs the job of the schedule, waits for the build and posts its result.
+func runScheduledJob(ctx context.Context, schedule *ScheduledJob) {
+	LogInfoContext(ctx, "[runScheduledJob] Running "+schedule.Job+" for schedule "+schedule.Id)
+
+	var result, msg, color string
+	number, status, err := runJobWaitForBuild(ctx, schedule.Job, schedule.Parameters, nil)
+	build := fmt.Sprintf("[%v #%v](%v)", schedule.Job, number, jenkinsBuildURL(schedule.Job, number))
+	if err != nil {
+		LogErrorContext(ctx, "[runScheduledJob] Unable to run "+schedule.Job+" for schedule "+schedule.Id+" err="+err.Error())
+		result = "error: " + err.Error()
+		if number > 0 {
+			msg = fmt.Sprintf("Unable to get the result of %v (`%v`): %v", build, schedule.Cron, err.Error())
+		} else {
+			msg = fmt.Sprintf("Unable to run **%v** (`%v`): %v", schedule.Job, schedule.Cron, err.Error())
+		}
+		color = "#e20025"
+	} else {
+		LogInfoContext(ctx, "[runScheduledJob] "+schedule.Job+" #"+strconv.FormatInt(number, 10)+" for schedule "+schedule.Id+" finished with "+status)
+		result = status + " " + build
+		msg = fmt.Sprintf("%v (`%v`) finished with **%v**", build, schedule.Cron, status)
+		if status == gojenkins.STATUS_SUCCESS {
+			color = "#86c323"
+		} else {
+			color = "#e20025"
+		}
+	}
+
+	postNotification(schedule.ChannelId, "Scheduled Job", msg, color)
+
+	err = updateSchedules(func(schedules []*ScheduledJob) ([]*ScheduledJob, bool, *AppError) {
+		for _, stored := range schedules {
+			if stored.Id == schedule.Id {
+				stored.LastResult = result
+				return schedules, true, nil
+			}
+		}
+		return schedules, false, nil
+	})
+	if err != nil {
+		LogErrorContext(ctx, "[runScheduledJob] Unable to save the result of schedule "+schedule.Id+" err="+err.Error())
+	}
+}
+
+// parseScheduleArgs splits `schedule add` arguments into the cron expression, the job and its KEY=VALUE parameters.
+func parseScheduleArgs(args []string) (string, string, map[string]string, *AppError) {
+	cronFieldsCount := len(cronFields)
+	if len(args) > 0 && strings.HasPrefix(args[0], "@") {
+		cronFieldsCount = 1
+	}
+	if len(args) < cronFieldsCount+1 {
+		return "", "", nil, NewError("You need to specify a cron expression and a job, e.g. `schedule add 0 6 * * 1-5 my-job KEY=value`.", nil)
+	}
+
+	cron := strings.Join(args[:cronFieldsCount], " ")
+	if parsed, err := ParseCron(cron); err != nil {
+		return "", "", nil, NewError("Bad cron expression.", err)
+	} else if parsed.Next(time.Now()).IsZero() {
+		return "", "", nil, NewError("The cron expression `"+cron+"` never runs.", nil)
+	}
+
+	job := args[cronFieldsCount]
+	parameters := map[string]string{}
+	for _, arg := range args[cronFieldsCount+1:] {
+		split := strings.SplitN(arg, "=", 2)
+		if len(split) != 2 || split[0] == "" {
+			return "", "", nil, NewError("Bad job parameter `"+arg+"`, use KEY=value.", nil)
+		}
+		parameters[split[0]] = split[1]
+	}
+
+	return cron, job, parameters, nil
+}
+
+func scheduleAddCmdF(args []string, w http.ResponseWriter, slashCommand *MMSlashCommand, channelId string) error {
+	cron, job, parameters, err := parseScheduleArgs(args)
+	if err != nil {
+		WriteErrorResponse(w, err)
+		return nil
+	}
+
+	if _, err := getJob(slashCommand.Context(), job); err != nil {
+		return err
+	}
+
+	if channelId == "" {
+		channelId = slashCommand.ChannelId
+	}
+
+	schedule := &ScheduledJob{
+		Id:         NewRequestId()[:8],
+		Cron:       cron,
+		Job:        job,
+		Parameters: parameters,
+		ChannelId:  channelId,
+		UserId:     slashCommand.UserId,
+		Username:   slashCommand.Username,
+		CreatedAt:  time.Now(),
+	}
+
+	err = updateSchedules(func(schedules []*ScheduledJob) ([]*ScheduledJob, bool, *AppError) {
+		return append(schedules, schedule), true, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	parsed, _ := ParseCron(cron)
+	LogInfoContext(slashCommand.Context(), "[scheduleAddCmdF] "+slashCommand.Username+" scheduled "+job+" at "+cron)
+	msg := fmt.Sprintf("Scheduled **%v** at `%v` with id `%v`. Next run: %v", job, cron, schedule.Id, parsed.Next(time.Now()).Format("2006-01-02 15:04 MST"))
+	WriteEnrichedResponse(w, "Schedule", msg, "#0060aa", IN_CHANNEL)
+	return nil
+}
+
+func scheduleListCmdF(args []string, w http.ResponseWriter, slashCommand *MMSlashCommand) error {
+	schedules, err := loadSchedules()
+	if err != nil {
+		return err
+	}
+
+	if len(schedules) == 0 {
+		WriteEnrichedResponse(w, "Schedule", "There are no scheduled jobs.", "#0060aa", EPHEMERAL)
+		return nil
+	}
+
+	sort.Slice(schedules, func(i, j int) bool {
+		return schedules[i].CreatedAt.Before(schedules[j].CreatedAt)
+	})
+
+	msg := "| Id | Cron | Job | Parameters | By | Next run | Last result |\n|---|---|---|---|---|---|---|\n"
+	for _, schedule := range schedules {
+		var parameters []string
+		for key, value := range schedule.Parameters {
+			parameters = append(parameters, key+"="+value)
+		}
+		sort.Strings(parameters)
+
+		next := ""
+		if parsed, err := ParseCron(schedule.Cron); err == nil {
+			next = parsed.Next(time.Now()).Format("2006-01-02 15:04 MST")
+		}
+
+		msg += fmt.Sprintf("| %v | `%v` | %v | %v | @%v | %v | %v |\n", schedule.Id, schedule.Cron, schedule.Job, strings.Join(parameters, " "), schedule.Username, next, schedule.LastResult)
+	}
+
+	WriteEnrichedResponse(w, "Schedule", msg, "#0060aa", EPHEMERAL)
+	return nil
+}
+
+func scheduleRemoveCmdF(args []string, w http.ResponseWriter, slashCommand *MMSlashCommand) error {
+	if len(args) < 1 {
+		return NewError("You need to specify the id of the schedule, see `schedule list`.", nil)
+	}
+
+	var removed *ScheduledJob
+	err := updateSchedules(func(schedules []*ScheduledJob) ([]*ScheduledJob, bool, *AppError) {
+		var kept []*ScheduledJob
+		for _, schedule := range schedules {
+			if schedule.Id == args[0] {
+				removed = schedule
+			} else {
+				kept = append(kept, schedule)
+			}
+		}
+		return kept, removed != nil, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if removed == nil {
+		WriteErrorResponse(w, NewError("There is no schedule with id `"+args[0]+"`.", nil))
+		return nil
+	}
+
+	LogInfoContext(slashCommand.Context(), "[scheduleRemoveCmdF] "+slashCommand.Username+" removed the schedule of "+removed.Job+" at "+removed.Cron)
+	msg := fmt.Sprintf("Removed the schedule of **%v** at `%v`.", removed.Job, removed.Cron)
+	WriteEnrichedResponse(w, "Schedule", msg, "#0060aa", IN_CHANNEL)
+	return nil
+}
@@ -0,0 +1,101 @@
+// Copyright (c) 2018-present Mattermost, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package server
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// waitForScheduleResults waits until the schedules ran in the background have saved their result.
+func waitForScheduleResults(t *testing.T, ids ...string) map[string]*ScheduledJob {
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		schedules, err := loadSchedules()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		byId := map[string]*ScheduledJob{}
+		done := 0
+		for _, schedule := range schedules {
+			byId[schedule.Id] = schedule
+			if schedule.LastResult != "" && containsOrEmpty(ids, schedule.Id) {
+				done++
+			}
+		}
+		if done == len(ids) {
+			return byId
+		}
+	}
+	t.Fatalf("expected the results of %v", ids)
+	return nil
+}
+
+func TestRunDueSchedules(t *testing.T) {
+	mattermost := newFakeMattermost()
+	defer mattermost.Close()
+	// Jenkins isn't reachable so the jobs fail right away, their failure is still posted and saved.
+	defer setTestConfig(t, mattermost.config(&MatterbuildConfig{JenkinsURL: "http://127.0.0.1:1"}))()
+
+	minute := time.Date(2018, 6, 4, 10, 0, 0, 0, time.Local)
+	if err := saveJSON(SCHEDULES_FILE, []*ScheduledJob{
+		// Missed yesterday and today at 6, it is only caught up once.
+		{Id: "missed", Cron: "0 6 * * *", Job: "nightly", ChannelId: "channel", LastRunAt: minute.AddDate(0, 0, -2).Add(-4 * time.Hour)},
+		// Due this minute.
+		{Id: "due", Cron: "*/5 * * * *", Job: "frequent", ChannelId: "channel", CreatedAt: minute.Add(-2 * time.Minute)},
+		// Not due before noon.
+		{Id: "later", Cron: "0 12 * * *", Job: "noon", ChannelId: "channel", CreatedAt: minute.AddDate(0, 0, -1).Add(3 * time.Hour)},
+		{Id: "invalid", Cron: "not a cron", Job: "broken", ChannelId: "channel"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	runDueSchedules(minute)
+
+	schedules := waitForScheduleResults(t, "missed", "due")
+	for _, id := range []string{"missed", "due"} {
+		if !schedules[id].LastRunAt.Equal(minute) || !strings.HasPrefix(schedules[id].LastResult, "error: ") {
+			t.Errorf("%v: expected to run at %v, got %+v", id, minute, schedules[id])
+		}
+	}
+	for _, id := range []string{"later", "invalid"} {
+		if !schedules[id].LastRunAt.IsZero() || schedules[id].LastResult != "" {
+			t.Errorf("%v: expected not to run, got %+v", id, schedules[id])
+		}
+	}
+
+	posts := mattermost.waitForPosts(t, 2)
+	for _, post := range posts {
+		if !strings.Contains(attachmentText(post), "Unable to run **") {
+			t.Errorf("expected the failure to be posted, got %q", attachmentText(post))
+		}
+	}
+
+	// Nothing is due anymore this minute, the schedules aren't run twice nor saved.
+	fileName := dataFilePath(SCHEDULES_FILE)
+	before := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(fileName, before, before); err != nil {
+		t.Fatal(err)
+	}
+
+	runDueSchedules(minute)
+	runDueSchedules(minute.Add(time.Minute))
+
+	time.Sleep(100 * time.Millisecond)
+	if posts := mattermost.Posts(); len(posts) != 2 {
+		t.Errorf("expected the schedules to run once, got %v posts", len(posts))
+	}
+	if info, err := os.Stat(fileName); err != nil || !info.ModTime().Equal(before) {
+		t.Errorf("expected the schedules not to be saved without a change (err=%v)", err)
+	}
+
+	// The next run is on time again.
+	runDueSchedules(minute.Add(5 * time.Minute))
+	mattermost.waitForPosts(t, 3)
+	if schedules, _ := loadSchedules(); !schedules[1].LastRunAt.Equal(minute.Add(5 * time.Minute)) {
+		t.Errorf("expected the next run of the schedule, got %+v", schedules[1])
+	}
+}
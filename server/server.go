@@ -5,12 +5,15 @@ package server
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"net/http"
-	"regexp"
-	"strconv"
+	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 
 	"github.com/bndr/gojenkins"
 	"github.com/gorilla/schema"
@@ -18,6 +21,7 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/mattermost/matterbuild/utils"
+	"github.com/mattermost/matterbuild/version"
 )
 
 const (
@@ -35,6 +39,23 @@ type MMSlashCommand struct {
 	Token       string `schema:"token"`
 	UserId      string `schema:"user_id"`
 	Username    string `schema:"user_name"`
+
+	ctx context.Context `schema:"-"`
+}
+
+// Context returns the context of the command, it carries the log fields of the request.
+func (c *MMSlashCommand) Context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
+// WithContext returns a shallow copy of the command with its context changed to ctx.
+func (c *MMSlashCommand) WithContext(ctx context.Context) *MMSlashCommand {
+	copied := *c
+	copied.ctx = ctx
+	return &copied
 }
 
 type AppError struct {
@@ -70,6 +91,7 @@ func Info(info string) {
 }
 
 func WriteErrorResponse(w http.ResponseWriter, err *AppError) {
+	markCommandFailed(w)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte(GenerateStandardSlashResponse(err.Error(), EPHEMERAL)))
@@ -87,6 +109,26 @@ func WriteEnrichedResponse(w http.ResponseWriter, title, resp, color, style stri
 	w.Write([]byte(GenerateEnrichedSlashResponse(title, resp, color, style)))
 }
 
+// WriteEnrichedErrorResponse writes a failure of the command as an attachment, it is recorded as an error
+// like the responses of WriteErrorResponse.
+func WriteEnrichedErrorResponse(w http.ResponseWriter, title, resp, style string) {
+	markCommandFailed(w)
+	WriteEnrichedResponse(w, title, resp, "#ee2116", style)
+}
+
+// commandResultWriter remembers whether the command wrote a failure, since many commands write their error
+// and return nil so that cobra doesn't print the usage.
+type commandResultWriter struct {
+	http.ResponseWriter
+	failed bool
+}
+
+func markCommandFailed(w http.ResponseWriter) {
+	if recorder, ok := w.(*commandResultWriter); ok {
+		recorder.failed = true
+	}
+}
+
 func ParseSlashCommand(r *http.Request) (*MMSlashCommand, error) {
 	err := r.ParseForm()
 	if err != nil {
@@ -104,38 +146,203 @@ func ParseSlashCommand(r *http.Request) (*MMSlashCommand, error) {
 	return inCommand, nil
 }
 
-func Start() {
-	LoadConfig("config.json")
+// Start loads the config file and serves matterbuild until it receives SIGINT or SIGTERM. A non empty
+// listenAddress takes precedence over the configured ListenAddress.
+func Start(configFile string, listenAddress string) error {
+	listenAddressOverride = listenAddress
+	if err := LoadConfig(configFile); err != nil {
+		LogError("Unable to start Matterbuild with an invalid config. err=" + err.Error())
+		return err
+	}
+	WatchConfig(configFile)
 	LogInfo("Starting Matterbuild")
 
+	reportPendingOperations()
+	markInterruptedReleases()
+	go runScheduler()
+
 	router := httprouter.New()
 	router.GET("/", indexHandler)
 	router.POST("/slash_command", slashCommandHandler)
+	router.POST("/outgoing_webhook", outgoingWebhookHandler)
+	router.POST("/hooks/jenkins", jenkinsHookHandler)
+	router.POST("/hooks/github", githubHookHandler)
+
+	srv := &http.Server{
+		Addr:    Cfg().ListenAddress,
+		Handler: filterRequests(router),
+	}
+
+	if Cfg().TLSCertFile != "" {
+		reloader, err := newCertificateReloader(Cfg().TLSCertFile, Cfg().TLSKeyFile)
+		if err != nil {
+			LogError("Unable to start Matterbuild with TLS. err=" + err.Error())
+			return err
+		}
+		srv.TLSConfig = &tls.Config{
+			GetCertificate: reloader.GetCertificate,
+			MinVersion:     tls.VersionTLS12,
+		}
+	}
+
+	serverErr := make(chan error, 1)
+	go func() {
+		if srv.TLSConfig != nil {
+			LogInfo("Running Matterbuild with TLS on port " + Cfg().ListenAddress)
+			serverErr <- srv.ListenAndServeTLS("", "")
+		} else {
+			LogInfo("Running Matterbuild on port " + Cfg().ListenAddress)
+			serverErr <- srv.ListenAndServe()
+		}
+	}()
+
+	metricsSrv := newMetricsServer()
+	if metricsSrv != nil {
+		go func() {
+			LogInfo("Serving the metrics on " + metricsSrv.Addr)
+			if err := metricsSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				LogError("Unable to serve the metrics. err=" + err.Error())
+			}
+		}()
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+
+	select {
+	case err := <-serverErr:
+		return err
+	case sig := <-stop:
+		LogInfo("Received " + sig.String() + ", shutting down")
+	}
 
-	LogInfo("Running Matterbuild on port " + Cfg.ListenAddress)
-	http.ListenAndServe(Cfg.ListenAddress, router)
+	// Keep serving while draining so Jenkins notifications still reach the builds being waited on,
+	// commands are refused in the meantime.
+	drainOperations()
 
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout())
+	defer cancel()
+	if metricsSrv != nil {
+		metricsSrv.Shutdown(ctx)
+	}
+	if err := srv.Shutdown(ctx); err != nil {
+		LogError("Error while shutting down the http server err=" + err.Error())
+		return err
+	}
+
+	LogInfo("Matterbuild stopped")
+	return nil
 }
 
 func indexHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 	w.Write([]byte("This is the matterbuild server."))
 }
 
-func checkSlashPermissions(command *MMSlashCommand) *AppError {
-	hasPremissions := false
-	for _, allowedToken := range Cfg.AllowedTokens {
-		if allowedToken == command.Token {
-			hasPremissions = true
-			break
+// resolveCommand finds the command of the command tree the text runs, the same way cobra does when it executes
+// it, so flags before the subcommand are handled, e.g. "cut" for "/matterbuild --dryrun=false cut 5.0.0".
+// The flags of the command are parsed. It returns an error if the text doesn't resolve to a command.
+func resolveCommand(command *MMSlashCommand) (*cobra.Command, *AppError) {
+	rootCmd := newRootCommand(nil, command)
+	found, flags, err := rootCmd.Find(strings.Fields(strings.TrimSpace(command.Text)))
+	if err != nil || found == nil {
+		return nil, NewError("Unknown command `"+command.Text+"`, see `"+command.Command+" help`.", err)
+	}
+
+	// cobra only adds the help flag when the command is executed.
+	if found.Flags().Lookup("help") == nil {
+		found.Flags().BoolP("help", "h", false, "help for "+found.Name())
+	}
+	if err := found.ParseFlags(flags); err != nil {
+		return nil, NewError("Bad flags for `"+command.Text+"`.", err)
+	}
+
+	return found, nil
+}
+
+// commandPath returns the path of a resolved command without the root, e.g. "cut" or "lock release",
+// or "" for the root command.
+func commandPath(cmd *cobra.Command) string {
+	return strings.TrimSpace(strings.TrimPrefix(cmd.CommandPath(), cmd.Root().Name()))
+}
+
+// topLevelCommand returns the name of the subcommand of the root a resolved command belongs to, e.g. "lock"
+// for "lock release".
+func topLevelCommand(cmd *cobra.Command) string {
+	for cmd.HasParent() && cmd.Parent().HasParent() {
+		cmd = cmd.Parent()
+	}
+	if !cmd.HasParent() {
+		return ""
+	}
+	return cmd.Name()
+}
+
+func containsOrEmpty(list []string, values ...string) bool {
+	if len(list) == 0 {
+		return true
+	}
+
+	for _, item := range list {
+		for _, value := range values {
+			if item == value {
+				return true
+			}
 		}
 	}
+	return false
+}
 
-	if !hasPremissions {
+// checkTokenPermissions verifies the token against AllowedTokens, which may run every command, and
+// ScopedTokens, which may only run the commands and teams they are configured for.
+func checkTokenPermissions(command *MMSlashCommand, cmd *cobra.Command) *AppError {
+	if command.Token == "" {
+		return NewError("Token for slash command is incorrect", nil)
+	}
+
+	hasPermissions := false
+	for _, allowedToken := range Cfg().AllowedTokens {
+		if utils.SecureCompare(command.Token, allowedToken) {
+			hasPermissions = true
+		}
+	}
+	if hasPermissions {
+		return nil
+	}
+
+	var scope *ScopedToken
+	for _, scopedToken := range Cfg().ScopedTokens {
+		if utils.SecureCompare(command.Token, scopedToken.Token) {
+			scope = scopedToken
+		}
+	}
+	if scope == nil {
 		return NewError("Token for slash command is incorrect", nil)
 	}
 
-	hasPremissions = false
-	for _, allowedUser := range Cfg.AllowedUsers {
+	if !containsOrEmpty(scope.Teams, command.TeamId, command.TeamName) {
+		return NewError("This command is not available in this team.", nil)
+	}
+
+	if subcommand := topLevelCommand(cmd); subcommand != "" && !containsOrEmpty(scope.Commands, subcommand, commandPath(cmd)) {
+		return NewError(fmt.Sprintf("`%v` is not available through %v. Available commands: %v", commandPath(cmd), command.Command, strings.Join(scope.Commands, ", ")), nil)
+	}
+
+	return nil
+}
+
+// checkSlashPermissions checks the token, the user and the freezes for the command the text resolves to.
+func checkSlashPermissions(command *MMSlashCommand) *AppError {
+	cmd, err := resolveCommand(command)
+	if err != nil {
+		return err
+	}
+
+	if err := checkTokenPermissions(command, cmd); err != nil {
+		return err
+	}
+
+	hasPremissions := false
+	for _, allowedUser := range Cfg().AllowedUsers {
 		if allowedUser == command.UserId {
 			hasPremissions = true
 			break
@@ -146,9 +353,9 @@ func checkSlashPermissions(command *MMSlashCommand) *AppError {
 		return NewError("You don't have permissions to use this command.", nil)
 	}
 
-	if command.Command == "cut" {
+	if topLevelCommand(cmd) == "cut" {
 		hasPremissions = false
-		for _, allowedUser := range Cfg.ReleaseUsers {
+		for _, allowedUser := range Cfg().ReleaseUsers {
 			if allowedUser == command.UserId {
 				hasPremissions = true
 				break
@@ -160,6 +367,10 @@ func checkSlashPermissions(command *MMSlashCommand) *AppError {
 		}
 	}
 
+	if err := checkFreeze(command, cmd); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -170,14 +381,113 @@ func slashCommandHandler(w http.ResponseWriter, r *http.Request, ps httprouter.P
 		return
 	}
 
-	if err := checkSlashPermissions(command); err != nil {
-		WriteErrorResponse(w, err)
+	handleSlashCommand(w, r, command.WithContext(commandContext(r.Context(), command)))
+}
+
+func handleSlashCommand(w http.ResponseWriter, r *http.Request, command *MMSlashCommand) {
+	response, _ := runSlashCommand(command, clientIP(r))
+	if response == nil {
+		w.WriteHeader(http.StatusOK)
 		return
 	}
 
+	writeSlashResponse(w, response)
+}
+
+// runSlashCommand checks the permissions, the shutdown and the rate limits, runs the command and returns its
+// response, or nil if it didn't write one. rejected reports the command wasn't run, the response then tells
+// the user why. source is where the command came from, for the audit log.
+func runSlashCommand(command *MMSlashCommand, source string) (response *MMSlashResponse, rejected bool) {
+	buffer := newBufferedResponseWriter()
+
+	if err := checkSlashPermissions(command); err != nil {
+		slashCommandsTotal.Inc(commandName(command), "rejected", "denied")
+		LogErrorContext(command.Context(), "[runSlashCommand] Permission denied for "+command.Username+" from "+source+" err="+err.Error())
+		WriteErrorResponse(buffer, err)
+		return buffer.SlashResponse(), true
+	}
+
+	if IsShuttingDown() {
+		slashCommandsTotal.Inc(commandName(command), "rejected", "allowed")
+		LogInfoContext(command.Context(), "[runSlashCommand] Rejected "+command.Text+" from "+command.Username+" during the shutdown")
+		WriteErrorResponse(buffer, NewError("Matterbuild is restarting, please try again in a few minutes.", nil))
+		return buffer.SlashResponse(), true
+	}
+
+	op, err := checkRateLimits(command)
+	if err != nil {
+		slashCommandsTotal.Inc(commandName(command), "rate_limited", "allowed")
+		LogInfoContext(command.Context(), "[runSlashCommand] Rate limited "+command.Username+" err="+err.Error())
+		WriteErrorResponse(buffer, err)
+		return buffer.SlashResponse(), true
+	}
+	if op != nil {
+		defer op.Finish()
+	}
+
+	LogInfoContext(command.Context(), "[runSlashCommand] User "+command.Username+" ("+command.UserId+") from "+source+" ran: "+command.Text)
+
+	recordCommandResult(command, executeCommand(buffer, command))
+	return buffer.SlashResponse(), false
+}
+
+func writeSlashResponse(w http.ResponseWriter, response *MMSlashResponse) {
+	b, err := json.Marshal(response)
+	if err != nil {
+		LogError("Unable to marshal response")
+		b = []byte("{}")
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(b)
+}
+
+func recordCommandResult(command *MMSlashCommand, err error) {
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	slashCommandsTotal.Inc(commandName(command), result, "allowed")
+}
+
+// executeCommand runs the matterbuild command tree for the text of the command and writes the slash response to w.
+// The returned error is the one of the command, or a generic one when the command wrote a failure and returned
+// nil. It has already been written to w.
+func executeCommand(w http.ResponseWriter, command *MMSlashCommand) error {
 	// Output Buffer
 	outBuf := &bytes.Buffer{}
 
+	recorder := &commandResultWriter{ResponseWriter: w}
+	w = recorder
+
+	rootCmd := newRootCommand(w, command)
+	rootCmd.SetArgs(strings.Fields(strings.TrimSpace(command.Text)))
+	rootCmd.SetOutput(outBuf)
+
+	err := rootCmd.Execute()
+
+	if err != nil || len(outBuf.String()) > 0 {
+		WriteEnrichedResponse(w, "Information", outBuf.String(), "#0060aa", EPHEMERAL)
+	}
+	if err == nil && recorder.failed {
+		return NewError("The command wrote a failure response", nil)
+	}
+	return err
+}
+
+// commandName returns the full name of the subcommand invoked, e.g. "cut", or "unknown". It only returns
+// names that exist, so it is safe to use as a metric label.
+func commandName(command *MMSlashCommand) string {
+	cmd, err := resolveCommand(command)
+	if err != nil {
+		return "unknown"
+	}
+	return commandPath(cmd)
+}
+
+// newRootCommand builds the command tree, the commands write their responses to w.
+func newRootCommand(w http.ResponseWriter, command *MMSlashCommand) *cobra.Command {
 	var rootCmd = &cobra.Command{
 		Use:   "matterbuild",
 		Short: "Control of the build system though MM slash commands!",
@@ -186,15 +496,34 @@ func slashCommandHandler(w http.ResponseWriter, r *http.Request, ps httprouter.P
 	var cutCmd = &cobra.Command{
 		Use:   "cut [release]",
 		Short: "Cut a release of Mattermost",
-		Long:  "Cut a release of Mattermost. Version should be specified in the format 0.0.0-rc0 or 0.0.0 for final releases.",
+		Long:  "Cut a release of Mattermost. Version should be specified in the format 0.0.0-rc0 or 0.0.0 for final releases, or use --next to get the next version suggested.",
 		RunE: func(cmd *cobra.Command, args []string) error {
 			backport, _ := cmd.Flags().GetBool("backport")
 			dryrun, _ := cmd.Flags().GetBool("dryrun")
+			next, _ := cmd.Flags().GetString("next")
+			releaseLine, _ := cmd.Flags().GetString("release")
+			confirm, _ := cmd.Flags().GetBool("confirm")
+			if confirm && (next != "" || releaseLine != "" || len(args) > 0) {
+				return NewError("--confirm cuts the version suggested by --next, it can't be used with --next, --release or a version.", nil)
+			}
+			if next != "" && len(args) > 0 {
+				return NewError("--next suggests the version to cut, it can't be used with a version.", nil)
+			}
+			if confirm {
+				return confirmNextCutCommandF(w, command)
+			}
+			if next != "" {
+				return proposeNextCutCommandF(next, releaseLine, w, command, dryrun)
+			}
 			return cutReleaseCommandF(args, w, command, backport, dryrun)
 		},
 	}
 	cutCmd.Flags().Bool("backport", false, "Set this flag for releases that are not on the current major release branch.")
 	cutCmd.Flags().Bool("dryrun", false, "Set this flag for testing the release build without pushing tags or artifacts.")
+	cutCmd.Flags().String("next", "", "Suggest the next version to cut from the git tags: rc, final or patch.")
+	cutCmd.Flags().String("release", "", "Release line to use with --next, e.g. 5.1. Defaults to the latest one.")
+	cutCmd.Flags().Bool("confirm", false, "Cut the version suggested by --next.")
+	cutCmd.Flags().Bool("force", false, "Cut during a release freeze, for release admins.")
 
 	var configDumpCmd = &cobra.Command{
 		Use:   "seeconf",
@@ -211,6 +540,7 @@ func slashCommandHandler(w http.ResponseWriter, r *http.Request, ps httprouter.P
 			return setCIBranchCmdF(args, w, command)
 		},
 	}
+	setCIBranchCmd.Flags().Bool("force", false, "Set the CI servers during a release freeze, for release admins.")
 
 	var runJobCmd = &cobra.Command{
 		Use:   "runjob",
@@ -269,6 +599,7 @@ func slashCommandHandler(w http.ResponseWriter, r *http.Request, ps httprouter.P
 		},
 	}
 	mergeReleaseBranchToMasterCmd.Flags().String("release", "", "Name of the release branch")
+	mergeReleaseBranchToMasterCmd.Flags().Bool("force", false, "Merge during a release freeze, for release admins.")
 
 	var loadtestKubeCmd = &cobra.Command{
 		Use:   "loadtest [buildtag]",
@@ -292,81 +623,213 @@ func slashCommandHandler(w http.ResponseWriter, r *http.Request, ps httprouter.P
 	loadtestKubeCmd.Flags().IntP("length", "l", 20, "How long to run the load test for in minutes.")
 	loadtestKubeCmd.Flags().IntP("delay", "d", 15, "How long to delay before running the pprof.")
 
-	rootCmd.SetArgs(strings.Fields(strings.TrimSpace(command.Text)))
-	rootCmd.SetOutput(outBuf)
+	var listReleasesCmd = &cobra.Command{
+		Use:   "releases",
+		Short: "List the releases that were cut.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			since, _ := cmd.Flags().GetString("since")
+			versionPrefix, _ := cmd.Flags().GetString("version")
+			return listReleasesCmdF(args, w, command, since, versionPrefix)
+		},
+	}
+	listReleasesCmd.Flags().String("since", "", "Only list the releases cut since a date like 2018-06-01 or a number of days like 30d.")
+	listReleasesCmd.Flags().String("version", "", "Only list the versions starting with this prefix, e.g. 5.1.")
 
-	rootCmd.AddCommand(cutCmd, configDumpCmd, setCIBranchCmd, runJobCmd, setPreReleaseCmd, checkCutReleaseStatusCmd, lockTranslationServerCmd, checkBranchTranslationCmd, mergeReleaseBranchToMasterCmd, loadtestKubeCmd)
+	var showReleaseCmd = &cobra.Command{
+		Use:   "release [version]",
+		Short: "Show the details of a release that was cut.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return showReleaseCmdF(args, w, command)
+		},
+	}
 
-	err = rootCmd.Execute()
+	var lockCmd = &cobra.Command{
+		Use:   "lock",
+		Short: "Manage the release lock held by cut, setci, setprerelease and merge.",
+	}
 
-	if err != nil || len(outBuf.String()) > 0 {
-		WriteEnrichedResponse(w, "Information", outBuf.String(), "#0060aa", EPHEMERAL)
+	var lockStatusCmd = &cobra.Command{
+		Use:   "status",
+		Short: "Show who holds the release lock.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return lockStatusCmdF(args, w, command)
+		},
 	}
-	return
-}
 
-var finalVersionRxp = regexp.MustCompile("^[0-9]+.[0-9]+.[0-9]+$")
-var rcRxp = regexp.MustCompile("^[0-9]+.[0-9]+.[0-9]+-rc[0-9]+$")
+	var lockReleaseCmd = &cobra.Command{
+		Use:   "release",
+		Short: "Release the lock, for release admins once they checked the state of the release.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return lockReleaseCmdF(args, w, command)
+		},
+	}
+	lockCmd.AddCommand(lockStatusCmd, lockReleaseCmd)
+
+	var freezeCmd = &cobra.Command{
+		Use:   "freeze",
+		Short: "Manage the release freezes that block cut, setci and merge.",
+	}
+
+	var freezeStartCmd = &cobra.Command{
+		Use:   "start [reason]",
+		Short: "Start a release freeze.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			until, _ := cmd.Flags().GetString("until")
+			return freezeStartCmdF(args, w, command, until)
+		},
+	}
+	freezeStartCmd.Flags().String("until", "", "End the freeze automatically at a date like 2018-06-01 (included) or 2018-06-01T09:00.")
+
+	var freezeEndCmd = &cobra.Command{
+		Use:   "end",
+		Short: "End the release freeze started with freeze start.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return freezeEndCmdF(args, w, command)
+		},
+	}
+
+	var freezeListCmd = &cobra.Command{
+		Use:   "list",
+		Short: "List the current and configured release freezes.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return freezeListCmdF(args, w, command)
+		},
+	}
+	freezeCmd.AddCommand(freezeStartCmd, freezeEndCmd, freezeListCmd)
+
+	var scheduleCmd = &cobra.Command{
+		Use:   "schedule",
+		Short: "Manage the jobs run on a schedule.",
+	}
+
+	var scheduleAddCmd = &cobra.Command{
+		Use:   "add [cron] [job] [KEY=value...]",
+		Short: "Run a job on a cron schedule, e.g. schedule add 0 6 * * 1-5 my-job KEY=value",
+		Long:  "Run a job on a cron schedule. The cron expression has 5 fields: minute hour day-of-month month day-of-week, or is one of @hourly, @daily, @weekly or @monthly. The results are posted to the channel.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			channelId, _ := cmd.Flags().GetString("channel")
+			return scheduleAddCmdF(args, w, command, channelId)
+		},
+	}
+	scheduleAddCmd.Flags().String("channel", "", "Id of the channel to post the results to. Defaults to the current channel.")
+
+	var scheduleListCmd = &cobra.Command{
+		Use:   "list",
+		Short: "List the scheduled jobs.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return scheduleListCmdF(args, w, command)
+		},
+	}
+
+	var scheduleRemoveCmd = &cobra.Command{
+		Use:   "remove [id]",
+		Short: "Remove a scheduled job.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return scheduleRemoveCmdF(args, w, command)
+		},
+	}
+	scheduleCmd.AddCommand(scheduleAddCmd, scheduleListCmd, scheduleRemoveCmd)
+
+	var calendarCmd = &cobra.Command{
+		Use:   "calendar",
+		Short: "Manage the release calendar and its reminders.",
+	}
+
+	var calendarAddCmd = &cobra.Command{
+		Use:   "add [version]",
+		Short: "Add or update the milestones of a release, e.g. calendar add 5.1.0 --rc1 2018-06-04 --final 2018-06-16",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			milestones := map[string]string{}
+			for _, milestone := range milestoneOrder {
+				milestones[milestone], _ = cmd.Flags().GetString(milestone)
+			}
+			channelId, _ := cmd.Flags().GetString("channel")
+			return calendarAddCmdF(args, w, command, milestones, channelId)
+		},
+	}
+	calendarAddCmd.Flags().String(MILESTONE_FEATURE_COMPLETE, "", "Date of the feature complete, e.g. 2018-06-01.")
+	calendarAddCmd.Flags().String(MILESTONE_RC1, "", "Date of the first release candidate, e.g. 2018-06-04.")
+	calendarAddCmd.Flags().String(MILESTONE_FINAL, "", "Date of the final release, e.g. 2018-06-16.")
+	calendarAddCmd.Flags().String("channel", "", "Id of the channel to post the reminders to when ReleaseChannelId isn't set. Defaults to the current channel.")
+
+	var calendarListCmd = &cobra.Command{
+		Use:   "list",
+		Short: "List the releases in the calendar.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return calendarListCmdF(args, w, command)
+		},
+	}
+
+	var calendarRemoveCmd = &cobra.Command{
+		Use:   "remove [version]",
+		Short: "Remove a release from the calendar.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return calendarRemoveCmdF(args, w, command)
+		},
+	}
+	calendarCmd.AddCommand(calendarAddCmd, calendarListCmd, calendarRemoveCmd)
+
+	var changelogCmd = &cobra.Command{
+		Use:   "changelog [from] [to]",
+		Short: "List the pull requests merged between two tags or branches, e.g. changelog v5.0.0 v5.1.0",
+		Long:  "List the pull requests merged between two tags or branches in all the repositories, grouped by label.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			attach, _ := cmd.Flags().GetBool("file")
+			return changelogCmdF(args, w, command, attach)
+		},
+	}
+	changelogCmd.Flags().Bool("file", false, "Post the changelog as a markdown file attached to the channel.")
+
+	// cobra only adds its help command when the tree is executed, it is added here so "help" resolves
+	// like the other commands.
+	var helpCmd = &cobra.Command{
+		Use:   "help [command]",
+		Short: "Help about any command",
+		Run: func(cmd *cobra.Command, args []string) {
+			found, _, err := cmd.Root().Find(args)
+			if found == nil || err != nil {
+				cmd.Printf("Unknown help topic %#q\n", args)
+				cmd.Root().Usage()
+			} else {
+				found.Help()
+			}
+		},
+	}
+	rootCmd.SetHelpCommand(helpCmd)
+
+	rootCmd.AddCommand(helpCmd, cutCmd, configDumpCmd, setCIBranchCmd, runJobCmd, setPreReleaseCmd, checkCutReleaseStatusCmd, lockTranslationServerCmd, checkBranchTranslationCmd, mergeReleaseBranchToMasterCmd, loadtestKubeCmd, listReleasesCmd, showReleaseCmd, lockCmd, freezeCmd, scheduleCmd, calendarCmd, changelogCmd)
+
+	return rootCmd
+}
 
 func cutReleaseCommandF(args []string, w http.ResponseWriter, slashCommand *MMSlashCommand, backport bool, dryrun bool) error {
 	if len(args) < 1 {
 		return NewError("You need to specifiy a release version.", nil)
 	}
 
-	versionString := args[0]
-
-	// Check the version string given and split into release part (0.0.0) and rc part (rc0)
-	// Also determine if this is RC1 of a .0 build in which case we need to branch
-	var releasePart string
-	var rcPart string
-	var isFirstMinorRelease bool
-
-	if rcRxp.MatchString(versionString) {
-		split := strings.Split(versionString, "-")
-		if len(split) != 2 {
-			WriteErrorResponse(w, NewError("Bad version argument. Can't split on -. Typo? If not the regex might be broken. If so be more careful!!", nil))
-			return nil
-		}
-		releasePart = split[0]
-		rcPart = split[1]
-		isFirstMinorRelease = (rcPart == "rc1" && strings.HasSuffix(releasePart, ".0"))
-	} else if finalVersionRxp.MatchString(versionString) {
-		releasePart = versionString
-		rcPart = ""
-		isFirstMinorRelease = false
-	} else {
-		WriteErrorResponse(w, NewError("Bad version argument. Typo? If not the regex might be broken. If so be more careful!!", nil))
+	v, err := version.ParseRelease(args[0])
+	if err != nil {
+		WriteErrorResponse(w, NewError("Bad version argument. Typo?", err))
 		return nil
 	}
 
+	return cutVersion(v, w, slashCommand, backport, dryrun)
+}
+
+func cutVersion(v *version.Version, w http.ResponseWriter, slashCommand *MMSlashCommand, backport bool, dryrun bool) error {
 	// Check that the release dev hasn't forgotten to get --backport
 	if !backport {
-		splitRelease := strings.Split(releasePart, ".")
-		if len(splitRelease) != 3 {
-			WriteErrorResponse(w, NewError("Bad version argument.", nil))
-			return nil
-		}
-		intVer, err := strconv.Atoi(splitRelease[1])
-		if err != nil {
-			WriteErrorResponse(w, NewError("Bad version argument.", nil))
-			return nil
-		}
-		splitRelease[1] = strconv.Itoa(intVer + 1)
-		splitRelease[2] = "0"
-
-		oneReleaseUp := strings.Join(splitRelease, ".")
-
-		s3URL := "http://releases.mattermost.com/" + oneReleaseUp + "-rc1/mattermost-" + oneReleaseUp + "-rc1-linux-amd64.tar.gz"
-		if resp, err := http.Get(s3URL); err == nil && resp.StatusCode == http.StatusOK {
-			WriteErrorResponse(w, NewError("Are you sure this isn't a backport release? I see a future release on s3. ("+oneReleaseUp+")"+http.StatusText(resp.StatusCode), nil))
+		if evidence := detectNewerRelease(slashCommand.Context(), v); len(evidence) > 0 {
+			msg := "Are you sure this isn't a backport release? I see a newer release than " + v.ShortRelease() + ":\n- " + strings.Join(evidence, "\n- ") + "\nUse `--backport` if it is."
+			WriteErrorResponse(w, NewError(msg, nil))
 			return nil
 		}
 	}
 
-	if err := CutRelease(releasePart, rcPart, isFirstMinorRelease, backport, dryrun); err != nil {
+	if report, err := CutRelease(v, backport, dryrun, slashCommand); err != nil {
 		WriteErrorResponse(w, err)
 	} else {
-		msg := fmt.Sprintf("Release **%v** is on the way.", args[0])
+		msg := fmt.Sprintf("Release **%v** is on the way.\n#### Pre-checks\n%v", v, report)
 		WriteEnrichedResponse(w, "Cut Release", msg, "#0060aa", IN_CHANNEL)
 	}
 	return nil
@@ -377,14 +840,19 @@ func configDumpCommandF(args []string, w http.ResponseWriter, slashCommand *MMSl
 		return NewError("You need to supply an argument", nil)
 	}
 
-	config, err := GetJobConfig(args[0])
+	config, err := GetJobConfig(slashCommand.Context(), args[0])
 	if err != nil {
 		return err
 	}
 
-	LogInfo("Config Dump sent... dump=" + config)
+	redacted := Redact(config)
+	if isRedacted(config, redacted) {
+		redacted = "_Some values of this config were masked._\n" + redacted
+	}
+
+	LogInfoContext(slashCommand.Context(), "Config Dump sent... dump="+redacted)
 
-	WriteResponse(w, config, IN_CHANNEL)
+	WriteResponse(w, redacted, IN_CHANNEL)
 	return nil
 }
 
@@ -393,12 +861,18 @@ func setCIBranchCmdF(args []string, w http.ResponseWriter, slashCommand *MMSlash
 		return NewError("You need to specify a branch", nil)
 	}
 
-	if err := SetCIServerBranch(args[0]); err != nil {
-		LogError("Error when setting the branch. err= " + err.Error())
+	lock, err := AcquireReleaseLock(slashCommand)
+	if err != nil {
 		return err
 	}
+	defer lock.Release()
 
-	LogInfo("CI servers now pointed at " + args[0])
+	if err := SetCIServerBranch(slashCommand.Context(), args[0]); err != nil {
+		LogErrorContext(slashCommand.Context(), "Error when setting the branch. err= "+err.Error())
+		return err
+	}
+
+	LogInfoContext(slashCommand.Context(), "CI servers now pointed at "+args[0])
 	msg := fmt.Sprintf("CI servers now pointed at **%v**", args[0])
 	WriteEnrichedResponse(w, "CI Servers", msg, "#0060aa", IN_CHANNEL)
 	return nil
@@ -409,7 +883,7 @@ func runJobCmdF(args []string, w http.ResponseWriter, slashCommand *MMSlashComma
 		return NewError("You need to specify a job", nil)
 	}
 
-	if err := RunJob(args[0]); err != nil {
+	if err := RunJob(slashCommand.Context(), args[0]); err != nil {
 		return err
 	}
 
@@ -423,7 +897,13 @@ func setPreReleaseCmdF(args []string, w http.ResponseWriter, slashCommand *MMSla
 		return NewError("You need to specify a target", nil)
 	}
 
-	if err := SetPreReleaseTarget(args[0]); err != nil {
+	lock, err := AcquireReleaseLock(slashCommand)
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
+	if err := SetPreReleaseTarget(slashCommand.Context(), args[0]); err != nil {
 		return err
 	}
 
@@ -434,14 +914,14 @@ func setPreReleaseCmdF(args []string, w http.ResponseWriter, slashCommand *MMSla
 }
 
 func checkCutReleaseStatusF(args []string, w http.ResponseWriter, slashCommand *MMSlashCommand) error {
-	LogInfo("Running Check Cut Release Status")
-	status, err := GetLatestResult(Cfg.ReleaseJob)
+	LogInfoContext(slashCommand.Context(), "Running Check Cut Release Status")
+	status, err := GetLatestResult(slashCommand.Context(), Cfg().ReleaseJob)
 	if err != nil {
-		LogError("[checkCutReleaseStatusF] Unable to get the Job: " + Cfg.ReleaseJob + " err=" + err.Error())
+		LogErrorContext(slashCommand.Context(), "[checkCutReleaseStatusF] Unable to get the Job: "+Cfg().ReleaseJob+" err="+err.Error())
 		return err
 	}
 
-	msg := fmt.Sprintf("Status of *%v*: **%v** Duration: **%v**", Cfg.ReleaseJob, status.Status, utils.MilisecsToMinutes(status.Duration))
+	msg := fmt.Sprintf("Status of *%v*: **%v** Duration: **%v**", Cfg().ReleaseJob, status.Status, utils.MilisecsToMinutes(status.Duration))
 
 	WriteEnrichedResponse(w, "Status of Jenkins Job", msg, status.Color, IN_CHANNEL)
 	return nil
@@ -451,21 +931,22 @@ func lockTranslationServerCommandF(args []string, w http.ResponseWriter, slashCo
 
 	if plt == "" && web == "" && mobile == "" {
 		msg := "You need to set at least one branch to lock. Please check the help."
-		WriteEnrichedResponse(w, "Translation Server Update", msg, "#ee2116", IN_CHANNEL)
+		WriteEnrichedErrorResponse(w, "Translation Server Update", msg, IN_CHANNEL)
 		return nil
 	}
 
 	result, err := RunJobWaitForResult(
-		Cfg.TranslationServerJob,
+		slashCommand.Context(),
+		Cfg().TranslationServerJob,
 		map[string]string{
 			"PLT_BRANCH": plt,
 			"WEB_BRANCH": web,
 			"RN_BRANCH":  mobile,
 		})
 	if err != nil || result != gojenkins.STATUS_SUCCESS {
-		LogError("Translation job failed. err= " + err.Error() + " Jenkins result= " + result)
+		LogErrorContext(slashCommand.Context(), "Translation job failed. err= "+err.Error()+" Jenkins result= "+result)
 		msg := fmt.Sprintf("Translation Job Fail. Please Check the Jenkins Logs. Jenkins Status: %v", result)
-		WriteEnrichedResponse(w, "Translation Server Update", msg, "#ee2116", IN_CHANNEL)
+		WriteEnrichedErrorResponse(w, "Translation Server Update", msg, IN_CHANNEL)
 		return nil
 	}
 
@@ -485,31 +966,17 @@ func lockTranslationServerCommandF(args []string, w http.ResponseWriter, slashCo
 }
 
 func checkBranchTranslationCmdF(args []string, w http.ResponseWriter, slashCommand *MMSlashCommand) error {
-	result, err := RunJobWaitForResult(Cfg.CheckTranslationServerJob, map[string]string{})
-	if err != nil || result != gojenkins.STATUS_SUCCESS {
-		LogError("Translation job failed. err= " + err.Error() + " Jenkins result= " + result)
-		msg := fmt.Sprintf("Translation Job Fail. Please Check the Jenkins Logs. Jenkins Status: %v", result)
-		WriteEnrichedResponse(w, "Translation Server Update", msg, "#ee2116", IN_CHANNEL)
-		return nil
-	}
-
-	artifacts, err := GetJenkinsArtifacts(Cfg.CheckTranslationServerJob)
+	branches, err := GetTranslationServerBranches(slashCommand.Context())
 	if err != nil {
-		return err
+		msg := fmt.Sprintf("Translation Job Fail. Please Check the Jenkins Logs. %v", err.Error())
+		WriteEnrichedErrorResponse(w, "Translation Server Update", msg, IN_CHANNEL)
+		return nil
 	}
-	file := fmt.Sprintf("/tmp/%v", artifacts[0].FileName)
-	dat, _ := ioutil.ReadFile(file)
 
-	tmpMsg := string(dat)
-	tmpMsg = strings.Replace(tmpMsg, "PLT_BRANCH=", "Server Branch:", -1)
-	tmpMsg = strings.Replace(tmpMsg, "WEB_BRANCH=", "Webapp Branch:", -1)
-	tmpMsg = strings.Replace(tmpMsg, "RN_BRANCH=", "Mobile Branch:", -1)
-	tmpMsg = strings.Replace(tmpMsg, "\"", " **", -1)
-	splittedMsg := strings.Split(tmpMsg, "\n")
 	msg := "Translation Server have lock to those Branches:\n"
-	for _, txt := range splittedMsg {
-		msg += fmt.Sprintf("%v\n", txt)
-	}
+	msg += fmt.Sprintf("Server Branch: **%v**\n", branches["PLT_BRANCH"])
+	msg += fmt.Sprintf("Webapp Branch: **%v**\n", branches["WEB_BRANCH"])
+	msg += fmt.Sprintf("Mobile Branch: **%v**\n", branches["RN_BRANCH"])
 
 	WriteEnrichedResponse(w, "Translation Server Update", msg, "#0060aa", IN_CHANNEL)
 
@@ -521,7 +988,13 @@ func mergeReleaseBranchToMasterCommandF(args []string, w http.ResponseWriter, sl
 		return NewError("You need to specifiy a release branch.", nil)
 	}
 
-	msg, err := CreateMergeAndPr(releaseBranch)
+	lock, err := AcquireReleaseLock(slashCommand)
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
+	msg, err := CreateMergeAndPr(slashCommand.Context(), releaseBranch)
 	if err != nil {
 		return err
 	}
@@ -536,7 +1009,7 @@ func loadtestKubeF(args []string, w http.ResponseWriter, slashCommand *MMSlashCo
 		return NewError("You need to specify a build tag. A branch or pr-0000.", nil)
 	}
 
-	if err := LoadtestKube(args[0], testLength, pprofDelay); err != nil {
+	if err := LoadtestKube(slashCommand.Context(), args[0], testLength, pprofDelay); err != nil {
 		return err
 	}
 
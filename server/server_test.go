@@ -0,0 +1,137 @@
+// Copyright (c) 2018-present Mattermost, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package server
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+// setTestConfig makes c the active config, with a temporary data directory if it has none. The returned
+// function restores the previous config.
+func setTestConfig(t *testing.T, c *MatterbuildConfig) func() {
+	dir, err := ioutil.TempDir("", "matterbuild")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.DataDirectory == "" {
+		c.DataDirectory = dir
+	}
+
+	previous := currentConfig.Load()
+	currentConfig.Store(c)
+
+	return func() {
+		if previous != nil {
+			currentConfig.Store(previous)
+		}
+		os.RemoveAll(dir)
+	}
+}
+
+func TestResolveCommand(t *testing.T) {
+	defer setTestConfig(t, &MatterbuildConfig{})()
+
+	for _, tc := range []struct {
+		text    string
+		path    string
+		invalid bool
+	}{
+		{text: "cut 5.0.0", path: "cut"},
+		{text: "--dryrun=false cut 5.0.0", path: "cut"},
+		{text: "-h=false cut 5.0.0", path: "cut"},
+		{text: "cut --dryrun 5.0.0", path: "cut"},
+		{text: "lock release", path: "lock release"},
+		{text: "  freeze   list ", path: "freeze list"},
+		{text: "help cut", path: "help"},
+		{text: "cut --help", path: "cut"},
+		{text: "", path: ""},
+		{text: "nosuchcommand", invalid: true},
+		{text: "--dryrun=false nosuchcommand cut", invalid: true},
+		{text: "cut 5.0.0 --nosuchflag", invalid: true},
+	} {
+		cmd, err := resolveCommand(&MMSlashCommand{Command: "/matterbuild", Text: tc.text})
+		if tc.invalid {
+			if err == nil {
+				t.Errorf("%q: expected an error, resolved to %q", tc.text, commandPath(cmd))
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error %v", tc.text, err)
+			continue
+		}
+		if path := commandPath(cmd); path != tc.path {
+			t.Errorf("%q: resolved to %q, expected %q", tc.text, path, tc.path)
+		}
+	}
+}
+
+func TestCheckSlashPermissions(t *testing.T) {
+	defer setTestConfig(t, &MatterbuildConfig{
+		AllowedTokens: []string{"full-token"},
+		ScopedTokens: []*ScopedToken{
+			{Token: "scoped-token", Commands: []string{"cutstatus", "lock status"}},
+		},
+		AllowedUsers: []string{"user", "releaser"},
+		ReleaseUsers: []string{"releaser"},
+	})()
+
+	for _, tc := range []struct {
+		name    string
+		token   string
+		userId  string
+		text    string
+		allowed bool
+	}{
+		{"full token", "full-token", "user", "cutstatus", true},
+		{"bad token", "bad-token", "user", "cutstatus", false},
+		{"unknown user", "full-token", "someone", "cutstatus", false},
+		{"unknown command", "full-token", "user", "nosuchcommand", false},
+		{"scoped command", "scoped-token", "user", "cutstatus", true},
+		{"scoped subcommand", "scoped-token", "user", "lock status", true},
+		{"scoped other subcommand", "scoped-token", "user", "lock release", false},
+		{"scoped other command", "scoped-token", "releaser", "cut 5.0.0", false},
+		{"scoped other command after a flag", "scoped-token", "releaser", "--dryrun=false cut 5.0.0", false},
+		{"scoped other command after help flag", "scoped-token", "releaser", "-h=false cut 5.0.0", false},
+		{"cut by a release user", "full-token", "releaser", "cut 5.0.0", true},
+		{"cut by another user", "full-token", "user", "cut 5.0.0", false},
+		{"cut after a flag by another user", "full-token", "user", "--dryrun=false cut 5.0.0", false},
+		{"cut after help flag by another user", "full-token", "user", "-h=false cut 5.0.0", false},
+	} {
+		err := checkSlashPermissions(&MMSlashCommand{
+			Command: "/matterbuild",
+			Token:   tc.token,
+			UserId:  tc.userId,
+			Text:    tc.text,
+		})
+		if tc.allowed && err != nil {
+			t.Errorf("%v: expected to be allowed, got %v", tc.name, err)
+		} else if !tc.allowed && err == nil {
+			t.Errorf("%v: expected to be denied", tc.name)
+		}
+	}
+}
+
+func TestExecuteCommandRecordsFailures(t *testing.T) {
+	defer setTestConfig(t, &MatterbuildConfig{})()
+
+	for _, tc := range []struct {
+		text   string
+		failed bool
+	}{
+		{text: "help", failed: false},
+		{text: "lockpootle", failed: true},
+		{text: "cut 5.0", failed: true},
+		{text: "cut v5.0.0", failed: true},
+		{text: "cut 5.0.0-beta1", failed: true},
+		{text: "cut 5.0.1-hotfix1", failed: true},
+	} {
+		err := executeCommand(newBufferedResponseWriter(), &MMSlashCommand{Command: "/matterbuild", Text: tc.text})
+		if failed := err != nil; failed != tc.failed {
+			t.Errorf("%q: failed=%v, expected %v (err=%v)", tc.text, failed, tc.failed, err)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright (c) 2018-present Mattermost, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package server
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+const DEFAULT_DATA_DIRECTORY = "data"
+
+func dataFilePath(name string) string {
+	directory := Cfg().DataDirectory
+	if directory == "" {
+		directory = DEFAULT_DATA_DIRECTORY
+	}
+	return filepath.Join(directory, name)
+}
+
+// saveJSON persists v in the data directory. The file is replaced atomically so a crash never leaves it half written.
+func saveJSON(name string, v interface{}) *AppError {
+	path := dataFilePath(name)
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return NewError("Unable to create the data directory for "+name, err)
+	}
+
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return NewError("Unable to marshal "+name, err)
+	}
+
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0640); err != nil {
+		return NewError("Unable to write "+name, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return NewError("Unable to replace "+name, err)
+	}
+
+	return nil
+}
+
+// loadJSON reads a file saved with saveJSON into v. It returns false if the file doesn't exist yet.
+func loadJSON(name string, v interface{}) (bool, *AppError) {
+	b, err := ioutil.ReadFile(dataFilePath(name))
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, NewError("Unable to read "+name, err)
+	}
+
+	if err := json.Unmarshal(b, v); err != nil {
+		return false, NewError("Unable to decode "+name, err)
+	}
+
+	return true, nil
+}
+
+// lockData takes an exclusive lock for the read-modify-write of the data file. The lock is shared with the other
+// matterbuild processes using the same data directory, like the local exec mode, and is released by the returned
+// function or when the process dies.
+func lockData(name string) (func(), *AppError) {
+	path := dataFilePath(name) + ".lock"
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return nil, NewError("Unable to create the data directory for "+name, err)
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0640)
+	if err != nil {
+		return nil, NewError("Unable to open the lock of "+name, err)
+	}
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX); err != nil {
+		file.Close()
+		return nil, NewError("Unable to lock "+name, err)
+	}
+
+	return func() {
+		syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+		file.Close()
+	}, nil
+}
+
+// updateJSON reads the file into v, lets update change it and saves it when update reports a change, all while
+// holding the lock of lockData. The data is read from disk on every update rather than kept in memory, so the
+// server and the local exec mode, which share the data directory, see each other's changes and don't overwrite them.
+func updateJSON(name string, v interface{}, update func() (bool, *AppError)) *AppError {
+	unlock, err := lockData(name)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if _, err := loadJSON(name, v); err != nil {
+		return err
+	}
+
+	changed, err := update()
+	if err != nil || !changed {
+		return err
+	}
+
+	return saveJSON(name, v)
+}
+
+func removeData(name string) {
+	if err := os.Remove(dataFilePath(name)); err != nil && !os.IsNotExist(err) {
+		LogError("Unable to remove " + name + " err=" + err.Error())
+	}
+}
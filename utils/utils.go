@@ -4,6 +4,7 @@
 package utils
 
 import (
+	"crypto/subtle"
 	"fmt"
 	"time"
 )
@@ -12,3 +13,8 @@ func MilisecsToMinutes(value int64) string {
 	str := fmt.Sprintf("%v", time.Duration(value)*time.Millisecond)
 	return str
 }
+
+// SecureCompare compares two secrets in constant time.
+func SecureCompare(given, expected string) bool {
+	return subtle.ConstantTimeCompare([]byte(given), []byte(expected)) == 1
+}
@@ -0,0 +1,183 @@
+// Copyright (c) 2018-present Mattermost, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+// Package version parses, compares and formats Mattermost release versions like 5.1.0, 5.1.0-rc2 or 5.1.0-beta1.
+package version
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	SUFFIX_BETA   = "beta"
+	SUFFIX_RC     = "rc"
+	SUFFIX_FINAL  = ""
+	SUFFIX_HOTFIX = "hotfix"
+)
+
+// Order of the suffixes of a same release. Hotfixes are built on top of the final release.
+var suffixOrder = map[string]int{
+	SUFFIX_BETA:   0,
+	SUFFIX_RC:     1,
+	SUFFIX_FINAL:  2,
+	SUFFIX_HOTFIX: 3,
+}
+
+var versionRxp = regexp.MustCompile(`^v?([0-9]+)\.([0-9]+)\.([0-9]+)(?:-(beta|rc|hotfix)([0-9]+))?$`)
+
+type Version struct {
+	Major        int
+	Minor        int
+	Patch        int
+	Suffix       string
+	SuffixNumber int
+}
+
+// Parse parses a version. A leading v, as used in git tags, is accepted.
+func Parse(s string) (*Version, error) {
+	matches := versionRxp.FindStringSubmatch(s)
+	if matches == nil {
+		return nil, fmt.Errorf("bad version %q, it should look like 0.0.0 for final releases or 0.0.0-rc0", s)
+	}
+
+	v := &Version{Suffix: matches[4]}
+	var err error
+	if v.Major, err = strconv.Atoi(matches[1]); err != nil {
+		return nil, err
+	}
+	if v.Minor, err = strconv.Atoi(matches[2]); err != nil {
+		return nil, err
+	}
+	if v.Patch, err = strconv.Atoi(matches[3]); err != nil {
+		return nil, err
+	}
+	if v.Suffix != SUFFIX_FINAL {
+		if v.SuffixNumber, err = strconv.Atoi(matches[5]); err != nil {
+			return nil, err
+		}
+	}
+
+	return v, nil
+}
+
+// ParseRelease parses a version that can be cut, a final release like 5.1.0 or a release candidate like
+// 5.1.0-rc2. Unlike Parse it refuses the leading v and the beta and hotfix suffixes.
+func ParseRelease(s string) (*Version, error) {
+	v, err := Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(s, "v") || !(v.IsFinal() || v.IsRC()) {
+		return nil, fmt.Errorf("bad version %q, only final releases like 0.0.0 and release candidates like 0.0.0-rc0 can be cut", s)
+	}
+	return v, nil
+}
+
+// String formats the version, e.g. 5.1.0-rc2.
+func (v *Version) String() string {
+	if v.Suffix == SUFFIX_FINAL {
+		return v.ReleasePart()
+	}
+	return v.ReleasePart() + "-" + v.SuffixPart()
+}
+
+// ReleasePart returns the version without suffix, e.g. 5.1.0.
+func (v *Version) ReleasePart() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// SuffixPart returns the suffix without dash, e.g. rc2, or an empty string for final releases.
+func (v *Version) SuffixPart() string {
+	if v.Suffix == SUFFIX_FINAL {
+		return ""
+	}
+	return v.Suffix + strconv.Itoa(v.SuffixNumber)
+}
+
+// ShortRelease returns the release line, e.g. 5.1.
+func (v *Version) ShortRelease() string {
+	return fmt.Sprintf("%d.%d", v.Major, v.Minor)
+}
+
+// ReleaseBranch returns the branch the version is built from, e.g. release-5.1.
+func (v *Version) ReleaseBranch() string {
+	return "release-" + v.ShortRelease()
+}
+
+func (v *Version) IsFinal() bool {
+	return v.Suffix == SUFFIX_FINAL
+}
+
+func (v *Version) IsRC() bool {
+	return v.Suffix == SUFFIX_RC
+}
+
+// IsFirstMinorRelease is true for the first build of a new minor release, x.y.0-rc1, which creates the release branch.
+func (v *Version) IsFirstMinorRelease() bool {
+	return v.Patch == 0 && v.IsRC() && v.SuffixNumber == 1
+}
+
+// NextMinor returns the first version of the next release line, e.g. 5.2.0 for 5.1.3-rc1.
+func (v *Version) NextMinor() *Version {
+	return &Version{Major: v.Major, Minor: v.Minor + 1}
+}
+
+// Compare returns -1, 0 or 1 if a is lower, equal or greater than b.
+func Compare(a, b *Version) int {
+	parts := [][2]int{
+		{a.Major, b.Major},
+		{a.Minor, b.Minor},
+		{a.Patch, b.Patch},
+		{suffixOrder[a.Suffix], suffixOrder[b.Suffix]},
+		{a.SuffixNumber, b.SuffixNumber},
+	}
+	for _, part := range parts {
+		if part[0] < part[1] {
+			return -1
+		} else if part[0] > part[1] {
+			return 1
+		}
+	}
+
+	return 0
+}
+
+// LessThan reports if v is lower than other.
+func (v *Version) LessThan(other *Version) bool {
+	return Compare(v, other) < 0
+}
+
+// NextRC returns the next release candidate, e.g. 5.1.0-rc3 for 5.1.0-rc2 and 5.1.1-rc1 for 5.1.0.
+func (v *Version) NextRC() *Version {
+	if v.IsRC() {
+		return &Version{Major: v.Major, Minor: v.Minor, Patch: v.Patch, Suffix: SUFFIX_RC, SuffixNumber: v.SuffixNumber + 1}
+	}
+	if v.IsFinal() || v.Suffix == SUFFIX_HOTFIX {
+		return &Version{Major: v.Major, Minor: v.Minor, Patch: v.Patch + 1, Suffix: SUFFIX_RC, SuffixNumber: 1}
+	}
+	return &Version{Major: v.Major, Minor: v.Minor, Patch: v.Patch, Suffix: SUFFIX_RC, SuffixNumber: 1}
+}
+
+// Final returns the final release of the version, e.g. 5.1.0 for 5.1.0-rc2.
+func (v *Version) Final() *Version {
+	return &Version{Major: v.Major, Minor: v.Minor, Patch: v.Patch}
+}
+
+// NextPatch returns the next patch release, e.g. 5.1.1 for 5.1.0 or 5.1.0-rc2.
+func (v *Version) NextPatch() *Version {
+	return &Version{Major: v.Major, Minor: v.Minor, Patch: v.Patch + 1}
+}
+
+// Latest returns the greatest of the versions, or nil if there are none.
+func Latest(versions []*Version) *Version {
+	var latest *Version
+	for _, v := range versions {
+		if latest == nil || latest.LessThan(v) {
+			latest = v
+		}
+	}
+	return latest
+}
@@ -0,0 +1,211 @@
+// Copyright (c) 2018-present Mattermost, Inc. All Rights Reserved.
+// See License.txt for license information.
+
+package version
+
+import (
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	for _, tc := range []struct {
+		input    string
+		expected *Version
+		invalid  bool
+	}{
+		{input: "5.1.0", expected: &Version{Major: 5, Minor: 1, Patch: 0}},
+		{input: "v5.1.0", expected: &Version{Major: 5, Minor: 1, Patch: 0}},
+		{input: "5.1.0-rc2", expected: &Version{Major: 5, Minor: 1, Patch: 0, Suffix: SUFFIX_RC, SuffixNumber: 2}},
+		{input: "4.10.12", expected: &Version{Major: 4, Minor: 10, Patch: 12}},
+		{input: "4.10.12-rc10", expected: &Version{Major: 4, Minor: 10, Patch: 12, Suffix: SUFFIX_RC, SuffixNumber: 10}},
+		{input: "5.2.0-beta1", expected: &Version{Major: 5, Minor: 2, Patch: 0, Suffix: SUFFIX_BETA, SuffixNumber: 1}},
+		{input: "5.1.1-hotfix3", expected: &Version{Major: 5, Minor: 1, Patch: 1, Suffix: SUFFIX_HOTFIX, SuffixNumber: 3}},
+		{input: "", invalid: true},
+		{input: "5.1", invalid: true},
+		{input: "5x1x0", invalid: true},
+		{input: "5.1.0-rc", invalid: true},
+		{input: "5.1.0-alpha1", invalid: true},
+		{input: "5.1.0 ", invalid: true},
+		{input: "V5.1.0", invalid: true},
+	} {
+		v, err := Parse(tc.input)
+		if tc.invalid {
+			if err == nil {
+				t.Errorf("%q: expected an error, got %v", tc.input, v)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error %v", tc.input, err)
+			continue
+		}
+		if *v != *tc.expected {
+			t.Errorf("%q: got %+v, expected %+v", tc.input, *v, *tc.expected)
+		}
+	}
+}
+
+func TestParseRelease(t *testing.T) {
+	for _, tc := range []struct {
+		input   string
+		invalid bool
+	}{
+		{input: "5.1.0"},
+		{input: "5.1.10"},
+		{input: "5.1.0-rc1"},
+		{input: "5.1.0-rc12"},
+		{input: "v5.1.0", invalid: true},
+		{input: "v5.1.0-rc1", invalid: true},
+		{input: "5.2.0-beta1", invalid: true},
+		{input: "5.1.1-hotfix1", invalid: true},
+		{input: "5.1", invalid: true},
+	} {
+		_, err := ParseRelease(tc.input)
+		if tc.invalid && err == nil {
+			t.Errorf("%q: expected an error", tc.input)
+		} else if !tc.invalid && err != nil {
+			t.Errorf("%q: unexpected error %v", tc.input, err)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	for _, tc := range []struct {
+		input         string
+		str           string
+		releasePart   string
+		suffixPart    string
+		shortRelease  string
+		releaseBranch string
+	}{
+		{"5.1.0", "5.1.0", "5.1.0", "", "5.1", "release-5.1"},
+		{"v5.1.0-rc2", "5.1.0-rc2", "5.1.0", "rc2", "5.1", "release-5.1"},
+		{"4.10.12", "4.10.12", "4.10.12", "", "4.10", "release-4.10"},
+		{"4.10.12-rc10", "4.10.12-rc10", "4.10.12", "rc10", "4.10", "release-4.10"},
+		{"10.0.0-beta1", "10.0.0-beta1", "10.0.0", "beta1", "10.0", "release-10.0"},
+	} {
+		v, err := Parse(tc.input)
+		if err != nil {
+			t.Fatalf("%q: %v", tc.input, err)
+		}
+		if got := v.String(); got != tc.str {
+			t.Errorf("%q: String() = %q, expected %q", tc.input, got, tc.str)
+		}
+		if got := v.ReleasePart(); got != tc.releasePart {
+			t.Errorf("%q: ReleasePart() = %q, expected %q", tc.input, got, tc.releasePart)
+		}
+		if got := v.SuffixPart(); got != tc.suffixPart {
+			t.Errorf("%q: SuffixPart() = %q, expected %q", tc.input, got, tc.suffixPart)
+		}
+		if got := v.ShortRelease(); got != tc.shortRelease {
+			t.Errorf("%q: ShortRelease() = %q, expected %q", tc.input, got, tc.shortRelease)
+		}
+		if got := v.ReleaseBranch(); got != tc.releaseBranch {
+			t.Errorf("%q: ReleaseBranch() = %q, expected %q", tc.input, got, tc.releaseBranch)
+		}
+	}
+}
+
+func TestLessThan(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		less bool
+	}{
+		{"5.1.0", "5.1.1", true},
+		{"5.1.1", "5.1.0", false},
+		{"5.1.0", "5.1.0", false},
+		{"5.1.9", "5.1.10", true},
+		{"5.9.0", "5.10.0", true},
+		{"4.10.0", "5.0.0", true},
+		{"5.1.0-beta2", "5.1.0-rc1", true},
+		{"5.1.0-rc1", "5.1.0-rc2", true},
+		{"5.1.0-rc9", "5.1.0-rc10", true},
+		{"5.1.0-rc10", "5.1.0", true},
+		{"5.1.0", "5.1.0-hotfix1", true},
+		{"5.1.0-hotfix1", "5.1.1-rc1", true},
+		{"5.1.0", "5.1.0-rc1", false},
+		{"5.1.0-hotfix1", "5.1.0", false},
+	} {
+		a, errA := Parse(tc.a)
+		b, errB := Parse(tc.b)
+		if errA != nil || errB != nil {
+			t.Fatalf("unable to parse %q or %q", tc.a, tc.b)
+		}
+		if got := a.LessThan(b); got != tc.less {
+			t.Errorf("%v < %v = %v, expected %v", tc.a, tc.b, got, tc.less)
+		}
+	}
+}
+
+func TestIsFirstMinorRelease(t *testing.T) {
+	for _, tc := range []struct {
+		input    string
+		expected bool
+	}{
+		{"5.1.0-rc1", true},
+		{"5.1.0-rc2", false},
+		{"5.1.0", false},
+		{"5.1.1-rc1", false},
+		{"5.1.0-beta1", false},
+		{"5.10.0-rc1", true},
+	} {
+		v, err := Parse(tc.input)
+		if err != nil {
+			t.Fatalf("%q: %v", tc.input, err)
+		}
+		if got := v.IsFirstMinorRelease(); got != tc.expected {
+			t.Errorf("%q: IsFirstMinorRelease() = %v, expected %v", tc.input, got, tc.expected)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	for _, tc := range []struct {
+		input     string
+		nextRC    string
+		final     string
+		nextPatch string
+		nextMinor string
+	}{
+		{"5.1.0-rc2", "5.1.0-rc3", "5.1.0", "5.1.1", "5.2.0"},
+		{"5.1.0", "5.1.1-rc1", "5.1.0", "5.1.1", "5.2.0"},
+		{"5.1.9", "5.1.10-rc1", "5.1.9", "5.1.10", "5.2.0"},
+		{"5.1.0-hotfix1", "5.1.1-rc1", "5.1.0", "5.1.1", "5.2.0"},
+		{"5.2.0-beta1", "5.2.0-rc1", "5.2.0", "5.2.1", "5.3.0"},
+	} {
+		v, err := Parse(tc.input)
+		if err != nil {
+			t.Fatalf("%q: %v", tc.input, err)
+		}
+		if got := v.NextRC().String(); got != tc.nextRC {
+			t.Errorf("%q: NextRC() = %q, expected %q", tc.input, got, tc.nextRC)
+		}
+		if got := v.Final().String(); got != tc.final {
+			t.Errorf("%q: Final() = %q, expected %q", tc.input, got, tc.final)
+		}
+		if got := v.NextPatch().String(); got != tc.nextPatch {
+			t.Errorf("%q: NextPatch() = %q, expected %q", tc.input, got, tc.nextPatch)
+		}
+		if got := v.NextMinor().String(); got != tc.nextMinor {
+			t.Errorf("%q: NextMinor() = %q, expected %q", tc.input, got, tc.nextMinor)
+		}
+	}
+}
+
+func TestLatest(t *testing.T) {
+	if Latest(nil) != nil {
+		t.Error("expected no latest version without versions")
+	}
+
+	var versions []*Version
+	for _, s := range []string{"5.1.0-rc2", "5.1.10", "5.1.9", "5.1.10-rc1"} {
+		v, err := Parse(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		versions = append(versions, v)
+	}
+	if latest := Latest(versions).String(); latest != "5.1.10" {
+		t.Errorf("Latest() = %v, expected 5.1.10", latest)
+	}
+}